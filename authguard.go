@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Errors of a login the server rejected because of the credentials. Retrying
+// them only counts towards an account lockout.
+var (
+	errWrongPassword = errors.New("密码错误")
+	errNoAccount     = errors.New("账号不存在")
+)
+
+// isCredentialError reports whether a login failed because of the credentials
+// rather than the network or the server
+func isCredentialError(err error) bool {
+	return errors.Is(err, errWrongPassword) || errors.Is(err, errNoAccount)
+}
+
+// Delay before the next relogin after the first failure, doubled for every
+// further consecutive failure up to the maximum
+const (
+	reloginFailureDelay    = 3 * time.Second
+	maxReloginFailureDelay = time.Minute
+)
+
+// loginGuard limits automatic relogins across all workers and egresses. Failed
+// relogins space out the following ones, and rejected credentials pause every
+// worker until the user enters new ones or stops the run.
+type loginGuard struct {
+	mu       sync.Mutex
+	failures int                // Consecutive failed relogins
+	paused   chan struct{}      // Closed when the pause ends, nil while not paused
+	retry    bool               // Whether the last prompt got new credentials
+	abort    context.CancelFunc // Stops the run in progress, nil outside a run
+	refused  bool               // The user stopped the run in progress at a prompt
+}
+
+// Global relogin guard
+var logins loginGuard
+
+// startRun lets a credential prompt stop the run with cancel
+func (g *loginGuard) startRun(cancel context.CancelFunc) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.abort = cancel
+	g.refused = false
+}
+
+// endRun forgets the cancel function of a finished run
+func (g *loginGuard) endRun() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.abort = nil
+	g.refused = false
+}
+
+// stopped reports whether the user stopped the run in progress instead of
+// entering new credentials
+func (g *loginGuard) stopped() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.refused
+}
+
+// credentials returns the stored username and password
+func (g *loginGuard) credentials() (string, string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return storedUsername, storedPassword
+}
+
+// wait blocks while the workers are paused for new credentials and reports
+// false if ctx was cancelled first or the user stopped the run
+func (g *loginGuard) wait(ctx context.Context) bool {
+	g.mu.Lock()
+	paused := g.paused
+	g.mu.Unlock()
+	if paused == nil {
+		return ctx.Err() == nil && !g.stopped()
+	}
+
+	select {
+	case <-paused:
+		return ctx.Err() == nil && !g.stopped()
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// delay returns how long to wait before the next relogin
+func (g *loginGuard) delay() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.failures == 0 {
+		return 0
+	}
+	d := reloginFailureDelay
+	for i := 1; i < g.failures && d < maxReloginFailureDelay; i++ {
+		d *= 2
+	}
+	return min(d, maxReloginFailureDelay)
+}
+
+// succeeded resets the failure count after a successful relogin
+func (g *loginGuard) succeeded() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures = 0
+}
+
+// failed records a failed relogin. Rejected credentials pause all workers and
+// ask for new ones; false is returned, and the run in progress is stopped, if
+// the user gives up instead.
+func (g *loginGuard) failed(err error) bool {
+	g.mu.Lock()
+	g.failures++
+	if !isCredentialError(err) {
+		g.mu.Unlock()
+		return true
+	}
+	if paused := g.paused; paused != nil {
+		// Another worker is already asking, go with its answer
+		g.mu.Unlock()
+		<-paused
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.retry
+	}
+	paused := make(chan struct{})
+	g.paused = paused
+	g.mu.Unlock()
+
+	username, password, ok := askCredentials(err)
+
+	g.mu.Lock()
+	if ok {
+		storedUsername = username
+		storedPassword = password
+		g.failures = 0
+	}
+	g.retry = ok
+	g.paused = nil
+	abort := g.abort
+	if !ok && abort != nil {
+		g.refused = true
+	}
+	g.mu.Unlock()
+	close(paused)
+
+	emitEvent("credentials", map[string]any{"error": errorText(err), "retry": ok})
+	if !ok && abort != nil {
+		abort()
+	}
+	return ok
+}
+
+// askCredentials tells the user why the workers stopped and asks for new
+// credentials, reporting false if the user chose to stop instead
+func askCredentials(cause error) (string, string, bool) {
+	promptMu.Lock()
+	defer promptMu.Unlock()
+	resume := suspendDashboard()
+	defer resume()
+
+	username, _ := logins.credentials()
+	fmt.Printf("\n⚠️  自动重新登录被拒绝: %v\n", cause)
+	fmt.Println("已暂停所有选课请求，继续使用错误的账号密码登录可能导致账号被锁定。")
+
+	input := readInput(fmt.Sprintf("请输入账号 (直接回车沿用 %s，输入 q 停止选课): ", username))
+	if input == "q" {
+		fmt.Println("已停止选课: 账号或密码错误，请确认后重新登录")
+		return "", "", false
+	}
+	if input != "" {
+		username = input
+	}
+
+	password := readInput("请输入密码 (直接回车停止选课): ")
+	if password == "" {
+		fmt.Println("已停止选课: 账号或密码错误，请确认后重新登录")
+		return "", "", false
+	}
+	fmt.Println("已更新登录凭据，恢复选课...")
+	return username, password, true
+}
+
+// errLoginRefused reports that the user stopped instead of entering new
+// credentials after a relogin was rejected
+var errLoginRefused = errors.New("已停止: 账号或密码被拒绝")
+
+// reloginEgress re-logs in through e on behalf of who, which names the caller
+// in the log; kch is the course reported in the relogin event, if any. Callers
+// sharing the egress wait for a relogin in progress and then reuse its session.
+// Relogins are spaced out after failures and held while the workers are
+// paused for new credentials. It returns an error only if ctx was cancelled
+// or the user gave up after the credentials were rejected.
+func reloginEgress(ctx context.Context, who string, kch string, e *egress) error {
+	e.loginMu.Lock()
+	defer e.loginMu.Unlock()
+
+	// Check if another goroutine has recently re-authenticated (within 5 seconds)
+	if e.sinceReauth() < 5*time.Second {
+		logf("%s: 另一个进程刚刚重新认证，等待使用新令牌...\n", who)
+		time.Sleep(1 * time.Second)
+		return ctx.Err()
+	}
+
+	if !logins.wait(ctx) || !sleepContext(ctx, logins.delay()) {
+		if logins.stopped() {
+			return errLoginRefused
+		}
+		return ctx.Err()
+	}
+
+	// Re-login and refresh authentication
+	stats.relogins.Add(1)
+	newCookies, err := relogin(e)
+	emitEvent("relogin", map[string]any{"kch": kch, "egress": e.name, "ok": err == nil, "error": errorText(err)})
+	if err != nil {
+		logf("%s: 重新登录失败: %v\n", who, err)
+		if !logins.failed(err) {
+			return errLoginRefused
+		}
+		return nil
+	}
+	logins.succeeded()
+
+	// Update egress cookies for all goroutines using it
+	e.setCookies(newCookies)
+	logf("%s: 已获取新的会话令牌\n", who)
+	return nil
+}
+
+// reloginPrimary logs the primary egress in again on behalf of a command that
+// keeps polling, such as wait or monitor, going through the same guarded path
+// as the course workers so that they do not log each other out
+func reloginPrimary(ctx context.Context) error {
+	return reloginEgress(ctx, "主会话", "", primaryEgress)
+}
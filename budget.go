@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// categoryLimit caps credits and course count within one 通选课类别
+type categoryLimit struct {
+	Credits float64 `json:"credits"` // 0 means no credit limit
+	Courses int     `json:"courses"` // 0 means no course limit
+}
+
+// creditBudget tracks what a run has selected against the configured limits
+type creditBudget struct {
+	mu sync.Mutex
+
+	maxCredits float64
+	maxCourses int
+	categories map[string]categoryLimit
+
+	credits         float64
+	courses         int
+	categoryCredits map[string]float64
+	categoryCourses map[string]int
+}
+
+// newCreditBudget creates a budget from the profile limits
+func newCreditBudget() *creditBudget {
+	return &creditBudget{
+		maxCredits:      profile.MaxCredits,
+		maxCourses:      profile.MaxCourses,
+		categories:      profile.CategoryLimits,
+		categoryCredits: make(map[string]float64),
+		categoryCourses: make(map[string]int),
+	}
+}
+
+// enabled reports whether any limit is configured
+func (b *creditBudget) enabled() bool {
+	return b.maxCredits > 0 || b.maxCourses > 0 || len(b.categories) > 0
+}
+
+// exceeded returns a description of the first limit the course would break,
+// or an empty string if it fits. The caller must hold b.mu.
+func (b *creditBudget) exceeded(c Course) string {
+	credits := float64(c.Xf)
+
+	if b.maxCredits > 0 && b.credits+credits > b.maxCredits {
+		return fmt.Sprintf("学分上限 %g", b.maxCredits)
+	}
+	if b.maxCourses > 0 && b.courses+1 > b.maxCourses {
+		return fmt.Sprintf("门数上限 %d", b.maxCourses)
+	}
+
+	name := b.categoryKey(c.Szkcflmc)
+	if name == "" {
+		return ""
+	}
+	limit := b.categories[name]
+	if limit.Credits > 0 && b.categoryCredits[name]+credits > limit.Credits {
+		return fmt.Sprintf("%s 学分上限 %g", name, limit.Credits)
+	}
+	if limit.Courses > 0 && b.categoryCourses[name]+1 > limit.Courses {
+		return fmt.Sprintf("%s 门数上限 %d", name, limit.Courses)
+	}
+	return ""
+}
+
+// categoryKey returns the configured category a szkcflmc belongs to. Limits
+// may name the full category or a part of it, e.g. "人文科学" matches
+// "人文科学（人文素养类）".
+func (b *creditBudget) categoryKey(szkcflmc string) string {
+	if _, ok := b.categories[szkcflmc]; ok {
+		return szkcflmc
+	}
+	for name := range b.categories {
+		if name != "" && strings.Contains(szkcflmc, name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// commit records a selected course
+func (b *creditBudget) commit(c Course) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.credits += float64(c.Xf)
+	b.courses++
+	if name := b.categoryKey(c.Szkcflmc); name != "" {
+		b.categoryCredits[name] += float64(c.Xf)
+		b.categoryCourses[name]++
+	}
+}
+
+// reason returns why the course no longer fits, or an empty string
+func (b *creditBudget) reason(c Course) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.exceeded(c)
+}
+
+// summary describes the usage of every configured limit
+func (b *creditBudget) summary() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var parts []string
+	if b.maxCredits > 0 {
+		parts = append(parts, fmt.Sprintf("学分 %g/%g", b.credits, b.maxCredits))
+	}
+	if b.maxCourses > 0 {
+		parts = append(parts, fmt.Sprintf("门数 %d/%d", b.courses, b.maxCourses))
+	}
+
+	names := make([]string, 0, len(b.categories))
+	for name := range b.categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		limit := b.categories[name]
+		if limit.Credits > 0 {
+			parts = append(parts, fmt.Sprintf("%s 学分 %g/%g", name, b.categoryCredits[name], limit.Credits))
+		}
+		if limit.Courses > 0 {
+			parts = append(parts, fmt.Sprintf("%s 门数 %d/%d", name, b.categoryCourses[name], limit.Courses))
+		}
+	}
+	return strings.Join(parts, "，")
+}
+
+// parseCategoryLimits parses "人文科学=4:2,艺术=2" into category limits, where
+// the number before the colon is the credit limit and the optional number
+// after it is the course limit
+func parseCategoryLimits(s string) (map[string]categoryLimit, error) {
+	limits := make(map[string]categoryLimit)
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("类别限制格式错误: %q，应为 类别=学分[:门数]", item)
+		}
+
+		var limit categoryLimit
+		creditsStr, coursesStr, hasCourses := strings.Cut(value, ":")
+		if creditsStr != "" {
+			credits, err := strconv.ParseFloat(creditsStr, 64)
+			if err != nil {
+				return nil, fmt.Errorf("类别 %s 的学分限制无效: %v", name, err)
+			}
+			limit.Credits = credits
+		}
+		if hasCourses {
+			courses, err := strconv.Atoi(coursesStr)
+			if err != nil {
+				return nil, fmt.Errorf("类别 %s 的门数限制无效: %v", name, err)
+			}
+			limit.Courses = courses
+		}
+
+		limits[strings.TrimSpace(name)] = limit
+	}
+
+	return limits, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseCategoryLimits covers credit and course limits and malformed items
+func TestParseCategoryLimits(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[string]categoryLimit
+		wantErr bool
+	}{
+		{"", map[string]categoryLimit{}, false},
+		{"人文科学=4", map[string]categoryLimit{"人文科学": {Credits: 4}}, false},
+		{"人文科学=4:2, 艺术=1.5", map[string]categoryLimit{"人文科学": {Credits: 4, Courses: 2}, "艺术": {Credits: 1.5}}, false},
+		{"体育=:1", map[string]categoryLimit{"体育": {Courses: 1}}, false},
+		{"人文科学=4,,", map[string]categoryLimit{"人文科学": {Credits: 4}}, false},
+		{"人文科学", nil, true},
+		{"=4", nil, true},
+		{"人文科学=四", nil, true},
+		{"人文科学=4:两", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseCategoryLimits(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestCreditBudgetExceeded checks the global limits before the category
+// limits, and that a category limit applies to every category containing
+// its name
+func TestCreditBudgetExceeded(t *testing.T) {
+	course := func(category string, credits float64) Course {
+		return Course{Kch: "K", Szkcflmc: category, Xf: flexFloat(credits)}
+	}
+	newBudget := func(maxCredits float64, maxCourses int, categories map[string]categoryLimit) *creditBudget {
+		return &creditBudget{
+			maxCredits:      maxCredits,
+			maxCourses:      maxCourses,
+			categories:      categories,
+			categoryCredits: make(map[string]float64),
+			categoryCourses: make(map[string]int),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		budget *creditBudget
+		held   []Course
+		next   Course
+		want   string
+	}{
+		{
+			name:   "no limits",
+			budget: newBudget(0, 0, nil),
+			held:   []Course{course("人文科学", 2), course("艺术", 2)},
+			next:   course("人文科学", 2),
+		},
+		{
+			name:   "global credits",
+			budget: newBudget(5, 0, nil),
+			held:   []Course{course("人文科学", 2), course("艺术", 2)},
+			next:   course("体育", 1.5),
+			want:   "学分上限 5",
+		},
+		{
+			name:   "global credits reached exactly",
+			budget: newBudget(5, 0, nil),
+			held:   []Course{course("人文科学", 2), course("艺术", 2)},
+			next:   course("体育", 1),
+		},
+		{
+			name:   "global courses",
+			budget: newBudget(0, 2, nil),
+			held:   []Course{course("人文科学", 1), course("艺术", 1)},
+			next:   course("体育", 1),
+			want:   "门数上限 2",
+		},
+		{
+			name:   "category credits by part of the name",
+			budget: newBudget(0, 0, map[string]categoryLimit{"人文科学": {Credits: 4}}),
+			held:   []Course{course("人文科学（人文素养类）", 2), course("人文科学（经典导读类）", 2)},
+			next:   course("人文科学（人文素养类）", 1),
+			want:   "人文科学 学分上限 4",
+		},
+		{
+			name:   "category courses",
+			budget: newBudget(0, 0, map[string]categoryLimit{"艺术": {Courses: 1}}),
+			held:   []Course{course("艺术（审美类）", 1)},
+			next:   course("艺术", 1),
+			want:   "艺术 门数上限 1",
+		},
+		{
+			name:   "other categories are not limited",
+			budget: newBudget(0, 0, map[string]categoryLimit{"艺术": {Courses: 1}}),
+			held:   []Course{course("艺术", 1)},
+			next:   course("人文科学", 1),
+		},
+		{
+			name:   "global limit before the category limit",
+			budget: newBudget(3, 0, map[string]categoryLimit{"艺术": {Credits: 2}}),
+			held:   []Course{course("艺术", 2), course("体育", 1)},
+			next:   course("艺术", 1),
+			want:   "学分上限 3",
+		},
+	}
+	for _, tt := range tests {
+		for _, c := range tt.held {
+			tt.budget.commit(c)
+		}
+		if got := tt.budget.reason(tt.next); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestCategoryKey checks exact and partial category names
+func TestCategoryKey(t *testing.T) {
+	b := &creditBudget{categories: map[string]categoryLimit{
+		"人文科学":        {Credits: 4},
+		"人文科学（经典导读类）": {Courses: 1},
+	}}
+	tests := map[string]string{
+		"人文科学（经典导读类）": "人文科学（经典导读类）",
+		"人文科学（人文素养类）": "人文科学",
+		"人文科学":        "人文科学",
+		"自然科学":        "",
+		"":            "",
+	}
+	for szkcflmc, want := range tests {
+		if got := b.categoryKey(szkcflmc); got != want {
+			t.Errorf("%q: got %q, want %q", szkcflmc, got, want)
+		}
+	}
+}
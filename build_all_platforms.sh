@@ -5,15 +5,15 @@ echo "Building executables for all platforms..."
 
 # Build for macOS (ARM64)
 echo "Building for macOS (ARM64)..."
-GOOS=darwin GOARCH=arm64 go build -o qzjwxt_xk_macos_arm64 main.go
+GOOS=darwin GOARCH=arm64 go build -o qzjwxt_xk_macos_arm64 .
 
 # Build for Linux (AMD64)
 echo "Building for Linux (AMD64)..."
-GOOS=linux GOARCH=amd64 go build -o qzjwxt_xk_linux_amd64 main.go
+GOOS=linux GOARCH=amd64 go build -o qzjwxt_xk_linux_amd64 .
 
 # Build for Windows (AMD64)
 echo "Building for Windows (AMD64)..."
-GOOS=windows GOARCH=amd64 go build -o qzjwxt_xk_windows_amd64.exe main.go
+GOOS=windows GOARCH=amd64 go build -o qzjwxt_xk_windows_amd64.exe .
 
 echo "All builds complete!"
 echo "- qzjwxt_xk_macos_arm64 (macOS ARM64)"
@@ -8,6 +8,6 @@ export GOOS=linux
 export GOARCH=amd64
 
 # Compile the application
-go build -o qzjwxt_xk_linux_amd64 main.go
+go build -o qzjwxt_xk_linux_amd64 .
 
 echo "Build complete: qzjwxt_xk_linux_amd64 (Linux AMD64)" 
\ No newline at end of file
@@ -8,6 +8,6 @@ export GOOS=darwin
 export GOARCH=arm64
 
 # Compile the application
-go build -o qzjwxt_xk_macos_arm64 main.go
+go build -o qzjwxt_xk_macos_arm64 .
 
 echo "Build complete: qzjwxt_xk_macos_arm64 (macOS ARM64)" 
\ No newline at end of file
@@ -8,6 +8,6 @@ export GOOS=windows
 export GOARCH=amd64
 
 # Compile the application
-go build -o qzjwxt_xk_windows_amd64.exe main.go
+go build -o qzjwxt_xk_windows_amd64.exe .
 
 echo "Build complete: qzjwxt_xk_windows_amd64.exe (Windows AMD64)" 
\ No newline at end of file
@@ -7,6 +7,6 @@ set GOOS=windows
 set GOARCH=amd64
 
 REM Compile the application
-go build -o qzjwxt_xk_windows_amd64.exe main.go
+go build -o qzjwxt_xk_windows_amd64.exe .
 
 echo Build complete: qzjwxt_xk_windows_amd64.exe (Windows AMD64) 
\ No newline at end of file
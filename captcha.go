@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// errCaptchaRequired is returned by a login attempt that the server rejected
+// because the captcha was missing or wrong
+var errCaptchaRequired = errors.New("需要验证码或验证码错误")
+
+// Number of captcha codes asked for before a login gives up
+const captchaAttempts = 3
+
+// Default form field carrying the captcha code next to encoded
+const defaultCaptchaField = "RANDOMCODE"
+
+// promptMu makes sure only one prompt, for a captcha or new credentials,
+// uses the terminal at a time
+var promptMu sync.Mutex
+
+// captchaField returns the form field name of the captcha code
+func captchaField() string {
+	if profile.CaptchaField == "" {
+		return defaultCaptchaField
+	}
+	return profile.CaptchaField
+}
+
+// solveCaptcha fetches a captcha image through an egress, shows it and asks
+// for the code. The returned cookies bind the code to the login request.
+func solveCaptcha(e *egress) ([]*http.Cookie, string, error) {
+	req, err := http.NewRequest("GET", siteURL("/verifycode.servlet"), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Host", siteHost())
+
+	resp, err := e.loginClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("获取验证码失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("读取验证码失败: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, "", fmt.Errorf("获取验证码失败，状态码: %d", resp.StatusCode)
+	}
+	cookies := resp.Cookies()
+
+	// Only one prompt at a time; the dashboard stays hidden while it is shown
+	promptMu.Lock()
+	defer promptMu.Unlock()
+	resume := suspendDashboard()
+	defer resume()
+
+	path, err := saveCaptcha(data, resp.Header.Get("Content-Type"))
+	if err != nil {
+		fmt.Printf("保存验证码图片失败: %v\n", err)
+	} else {
+		fmt.Printf("\n%s 需要验证码，图片已保存到 %s\n", e.name, path)
+	}
+	emitEvent("captcha", map[string]any{"egress": e.name, "path": path})
+
+	if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+		fmt.Print(renderBlockArt(img, 80))
+	}
+
+	code := readInput("请输入验证码 (直接回车放弃): ")
+	if code == "" {
+		return nil, "", fmt.Errorf("已放弃输入验证码")
+	}
+	return cookies, code, nil
+}
+
+// saveCaptcha writes the captcha image to the configured file, or to the
+// temporary directory, with an extension matching its type
+func saveCaptcha(data []byte, contentType string) (string, error) {
+	path := profile.CaptchaFile
+	if path == "" {
+		ext := ".jpg"
+		switch {
+		case strings.Contains(contentType, "png"):
+			ext = ".png"
+		case strings.Contains(contentType, "gif"):
+			ext = ".gif"
+		}
+		path = filepath.Join(os.TempDir(), "qzjwxt_xk_captcha"+ext)
+	}
+	return path, os.WriteFile(path, data, 0600)
+}
+
+// renderBlockArt draws an image with upper half blocks, two pixel rows per
+// text line, scaled down to at most maxCols columns. Terminals without ANSI
+// support get a grayscale character ramp instead.
+func renderBlockArt(img image.Image, maxCols int) string {
+	bounds := img.Bounds()
+	if width := terminalWidth(os.Stdout.Fd()); width > 1 && width-1 < maxCols {
+		maxCols = width - 1
+	}
+	scale := (bounds.Dx() + maxCols - 1) / maxCols
+	if scale < 1 {
+		scale = 1
+	}
+
+	pixel := func(x, y int) (uint8, uint8, uint8) {
+		px := bounds.Min.X + x*scale
+		py := bounds.Min.Y + y*scale
+		if px >= bounds.Max.X || py >= bounds.Max.Y {
+			return 255, 255, 255
+		}
+		r, g, b, _ := img.At(px, py).RGBA()
+		return uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)
+	}
+
+	cols := (bounds.Dx() + scale - 1) / scale
+	rows := (bounds.Dy() + scale - 1) / scale
+
+	const ramp = "@%#*+=-:. "
+	var out strings.Builder
+	for y := 0; y < rows; y += 2 {
+		for x := 0; x < cols; x++ {
+			r1, g1, b1 := pixel(x, y)
+			r2, g2, b2 := pixel(x, y+1)
+			if ansiEnabled {
+				fmt.Fprintf(&out, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀", r1, g1, b1, r2, g2, b2)
+				continue
+			}
+			luma := (int(r1) + int(g1) + int(b1) + int(r2) + int(g2) + int(b2)) / 6
+			out.WriteByte(ramp[luma*(len(ramp)-1)/255])
+		}
+		if ansiEnabled {
+			out.WriteString("\x1b[0m")
+		}
+		out.WriteByte('\n')
+	}
+	return out.String()
+}
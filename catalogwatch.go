@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// watchCatalog reloads the catalog every interval until ctx is done. During
+// long runs the school opens new sections and sometimes reissues jx0404ids, so
+// targets whose section disappeared are moved to the matching new one, and new
+// sections of a target course or matching a watch filter are announced.
+func watchCatalog(ctx context.Context, targets []*courseTarget, interval time.Duration) {
+	for sleepContext(ctx, interval) {
+		previous := courseCatalog
+		courses, err := loadCourseList(primaryEgress.getCookies())
+		if errors.Is(err, errSessionExpired) {
+			// Workers on a proxy pool never relogin the primary egress
+			logf("刷新课程列表时登录已过期，重新登录...\n")
+			if reloginPrimary(ctx) != nil {
+				return
+			}
+			courses, err = loadCourseList(primaryEgress.getCookies())
+		}
+		if err != nil {
+			logf("刷新课程列表失败: %v\n", err)
+			continue
+		}
+
+		used := resolveTargets(targets, courses)
+		announceNewSections(previous, courses, targets, used)
+	}
+}
+
+// resolveTargets updates the sections of the active targets from a fresh
+// catalog and returns the jx0404ids the targets now request
+func resolveTargets(targets []*courseTarget, courses []Course) map[string]bool {
+	byID := make(map[string]Course)
+	for _, c := range courses {
+		byID[c.Jx0404id] = c
+	}
+
+	used := make(map[string]bool)
+	for _, t := range targets {
+		current := t.currentSection()
+		used[current.Jx0404id] = true
+		if state := t.snapshot().state; state != "等待" && state != "选课中" {
+			continue
+		}
+
+		// Still listed: keep the fresh seat counts
+		if c, ok := byID[current.Jx0404id]; ok {
+			t.setSection(c)
+			continue
+		}
+
+		c, ok := resolveSection(current, courses)
+		if !ok {
+			logf("⚠️  课程 %s 的教学班 %s 已不在课程列表中，且没有找到对应的新教学班\n", t.kch, current.Jx0404id)
+			continue
+		}
+		logf("课程 %s 的选课ID已由 %s 变为 %s (%s %s)\n", t.kch, current.Jx0404id, c.Jx0404id, c.Skls, c.Sksj)
+		emitEvent("section_reissued", map[string]any{"kch": t.kch, "from": current.Jx0404id, "to": c.Jx0404id})
+		t.setSection(c)
+		used[c.Jx0404id] = true
+	}
+	return used
+}
+
+// resolveSection finds the section of the same course that replaced old,
+// matching the class name and teacher, then the time. A section sharing
+// neither class name nor teacher is not taken as a replacement, and neither
+// is one of several equally good candidates.
+func resolveSection(old Course, courses []Course) (Course, bool) {
+	var best Course
+	bestScore, ties := 0, 0
+	for _, c := range courses {
+		if c.Kch != old.Kch {
+			continue
+		}
+
+		score := 0
+		if old.Ktmc != "" && c.Ktmc == old.Ktmc {
+			score += 4
+		}
+		if old.Skls != "" && c.Skls == old.Skls {
+			score += 2
+		}
+		if old.Sksj != "" && c.Sksj == old.Sksj {
+			score++
+		}
+
+		switch {
+		case score > bestScore:
+			best, bestScore, ties = c, score, 1
+		case score == bestScore:
+			ties++
+		}
+	}
+	if bestScore < 2 || ties > 1 {
+		return Course{}, false
+	}
+	return best, true
+}
+
+// announceNewSections logs the sections missing from the previous catalog
+// that belong to a target course or match a watch filter. Sections the
+// targets moved to were already announced as reissued.
+func announceNewSections(previous []Course, courses []Course, targets []*courseTarget, used map[string]bool) {
+	if len(previous) == 0 {
+		return
+	}
+
+	known := make(map[string]bool)
+	for _, c := range previous {
+		known[c.Jx0404id] = true
+	}
+	targetCodes := make(map[string]bool)
+	for _, t := range targets {
+		targetCodes[t.kch] = true
+	}
+
+	for _, c := range courses {
+		if known[c.Jx0404id] || used[c.Jx0404id] {
+			continue
+		}
+		if !targetCodes[c.Kch] && !matchesWatch(c) {
+			continue
+		}
+		logf("🆕 新开放教学班: %s %s %s %s 剩余 %s (选课ID %s)\n", c.Kch, c.Kcmc, c.Skls, c.Sksj, c.Syrs, c.Jx0404id)
+		emitEvent("section_opened", map[string]any{
+			"kch":      c.Kch,
+			"jx0404id": c.Jx0404id,
+			"name":     c.Kcmc,
+			"teacher":  c.Skls,
+			"time":     c.Sksj,
+			"seats":    int(c.Syrs),
+		})
+	}
+}
+
+// matchesWatch reports whether a section matches any watch filter of the profile
+func matchesWatch(c Course) bool {
+	for _, watch := range profile.Watch {
+		if filters := strings.Fields(watch); len(filters) > 0 && courseMatches(c, filters) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+// TestResolveSection checks which section is taken as the replacement of a
+// reissued one
+func TestResolveSection(t *testing.T) {
+	old := Course{Kch: "A1", Jx0404id: "1", Ktmc: "高数1班", Skls: "张", Sksj: "周一 1-2节"}
+	tests := []struct {
+		name    string
+		courses []Course
+		want    string // jx0404id, "" if none is taken
+	}{
+		{
+			name: "same class name",
+			courses: []Course{
+				{Kch: "A1", Jx0404id: "2", Ktmc: "高数1班", Skls: "李", Sksj: "周三 3-4节"},
+				{Kch: "A1", Jx0404id: "3", Ktmc: "高数2班", Skls: "王", Sksj: "周一 1-2节"},
+			},
+			want: "2",
+		},
+		{
+			name: "same teacher",
+			courses: []Course{
+				{Kch: "A1", Jx0404id: "2", Ktmc: "高数3班", Skls: "张", Sksj: "周三 3-4节"},
+				{Kch: "A1", Jx0404id: "3", Ktmc: "高数2班", Skls: "王", Sksj: "周一 1-2节"},
+			},
+			want: "2",
+		},
+		{
+			name: "time alone is not enough",
+			courses: []Course{
+				{Kch: "A1", Jx0404id: "2", Ktmc: "高数3班", Skls: "李", Sksj: "周一 1-2节"},
+			},
+		},
+		{
+			name: "time breaks a tie of teachers",
+			courses: []Course{
+				{Kch: "A1", Jx0404id: "2", Skls: "张", Sksj: "周三 3-4节"},
+				{Kch: "A1", Jx0404id: "3", Skls: "张", Sksj: "周一 1-2节"},
+			},
+			want: "3",
+		},
+		{
+			name: "tie is rejected",
+			courses: []Course{
+				{Kch: "A1", Jx0404id: "2", Skls: "张", Sksj: "周三 3-4节"},
+				{Kch: "A1", Jx0404id: "3", Skls: "张", Sksj: "周五 5-6节"},
+			},
+		},
+		{
+			name: "other course codes are ignored",
+			courses: []Course{
+				{Kch: "B1", Jx0404id: "2", Ktmc: "高数1班", Skls: "张", Sksj: "周一 1-2节"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, ok := resolveSection(old, tt.courses)
+		if ok != (tt.want != "") || (ok && got.Jx0404id != tt.want) {
+			t.Errorf("%s: got %q (%v), want %q", tt.name, got.Jx0404id, ok, tt.want)
+		}
+	}
+}
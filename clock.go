@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Number of round trips used to estimate the server clock
+const clockSamples = 8
+
+// serverOffset is the estimated server clock minus the local clock
+var serverOffset time.Duration
+
+// serverNow returns the current time according to the server clock
+func serverNow() time.Time {
+	return time.Now().Add(serverOffset)
+}
+
+// clockEstimate is the result of measuring the server clock
+type clockEstimate struct {
+	offset      time.Duration // Server clock minus local clock
+	uncertainty time.Duration // Half width of the interval the offset lies in
+	rtt         time.Duration // Median round trip time
+	samples     int
+}
+
+// estimateClockOffset compares the Date header of several responses with the
+// local clock. The header only has second resolution, but each sample bounds
+// the offset: the server stamped the response at some point between sending
+// and receiving, within the second named by the header. Intersecting these
+// bounds over samples spread across a second narrows the offset well below the
+// header resolution, and corrects for the round trip at the same time.
+func estimateClockOffset(e *egress, samples int) (clockEstimate, error) {
+	var est clockEstimate
+
+	lower := time.Duration(math.MinInt64)
+	upper := time.Duration(math.MaxInt64)
+	var midpoints []time.Duration
+	var rtts []time.Duration
+
+	for i := 0; i < samples; i++ {
+		if i > 0 {
+			// Shift each sample to a different fraction of a second
+			time.Sleep(time.Second/time.Duration(samples) + 37*time.Millisecond)
+		}
+
+		req, err := http.NewRequest("GET", siteURL("/"), nil)
+		if err != nil {
+			return est, err
+		}
+		req.Header.Set("Host", siteHost())
+
+		sent := time.Now()
+		resp, err := e.loginClient.Do(req)
+		received := time.Now()
+		if err != nil {
+			continue
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		date, err := http.ParseTime(resp.Header.Get("Date"))
+		if err != nil {
+			continue
+		}
+
+		if d := date.Sub(received); d > lower {
+			lower = d
+		}
+		if d := date.Add(time.Second).Sub(sent); d < upper {
+			upper = d
+		}
+		midpoints = append(midpoints, date.Add(time.Second/2).Sub(sent.Add(received.Sub(sent)/2)))
+		rtts = append(rtts, received.Sub(sent))
+	}
+
+	if len(rtts) == 0 {
+		return est, fmt.Errorf("没有收到带有 Date 响应头的响应")
+	}
+
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+	est.rtt = rtts[len(rtts)/2]
+	est.samples = len(rtts)
+
+	if lower <= upper {
+		est.offset = lower + (upper-lower)/2
+		est.uncertainty = (upper - lower) / 2
+	} else {
+		// Inconsistent bounds (e.g. a load balancer with several clocks), use the median
+		sort.Slice(midpoints, func(i, j int) bool { return midpoints[i] < midpoints[j] })
+		est.offset = midpoints[len(midpoints)/2]
+		est.uncertainty = time.Second / 2
+	}
+
+	return est, nil
+}
+
+// syncServerClock measures the server clock, stores the offset and reports it
+func syncServerClock(e *egress) {
+	fmt.Println("\n正在测量服务器时间偏差...")
+
+	est, err := estimateClockOffset(e, clockSamples)
+	if err != nil {
+		fmt.Printf("测量服务器时间失败，将使用本机时间: %v\n", err)
+		return
+	}
+
+	serverOffset = est.offset
+	fmt.Printf("服务器时间偏差: %+v (±%v)，网络延迟: %v，样本数: %d\n",
+		est.offset.Round(time.Millisecond), est.uncertainty.Round(time.Millisecond),
+		est.rtt.Round(time.Millisecond), est.samples)
+	if est.offset > time.Second || est.offset < -time.Second {
+		fmt.Println("⚠️  本机时钟与服务器相差超过 1 秒，所有定时将以服务器时间为准")
+	}
+}
+
+// waitUntilServerTime sleeps until the server clock reaches start, printing a
+// countdown, and returns early with an error if ctx is cancelled
+func waitUntilServerTime(ctx context.Context, start time.Time) error {
+	fmt.Printf("将在服务器时间 %s 开始选课\n", start.In(chinaTime).Format("2006-01-02 15:04:05.000"))
+
+	for {
+		remaining := start.Sub(serverNow())
+		if remaining <= 0 {
+			return nil
+		}
+
+		var step time.Duration
+		switch {
+		case remaining > time.Minute:
+			fmt.Printf("距离开始还有 %v\n", remaining.Round(time.Second))
+			step = remaining - time.Minute
+			if step > time.Minute {
+				step = time.Minute
+			}
+		case remaining > 10*time.Second:
+			fmt.Printf("距离开始还有 %v\n", remaining.Round(time.Second))
+			step = remaining - 10*time.Second
+		case remaining > time.Second:
+			fmt.Printf("距离开始还有 %v\n", remaining.Round(time.Second))
+			step = time.Second
+		default:
+			step = remaining
+		}
+		if !sleepContext(ctx, step) {
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Reads of the 已选课程 list per claimed selection
+const (
+	confirmReads   = 3
+	confirmBackoff = time.Second
+)
+
+// confirmSelection checks a claimed selection against the 已选课程 list. It
+// reports true if the list shows the course, or if the list cannot be read, in
+// which case the course is marked unconfirmed. It reports false if the list
+// was read and the course is not on it, so that the worker keeps trying.
+func confirmSelection(ctx context.Context, t *courseTarget, e *egress) bool {
+	id := t.currentSection().Jx0404id
+	for read := 1; read <= confirmReads; read++ {
+		courses, err := fetchSelectedCourses(e)
+		if err == nil {
+			for _, c := range courses {
+				if c.Jx0404id == id {
+					t.setConfirmed(true)
+					logf("课程 %s 已在已选课程中确认\n", t.kch)
+					return true
+				}
+			}
+
+			// The list may lag behind the selection for a moment
+			if read == confirmReads || !sleepContext(ctx, confirmBackoff) {
+				return false
+			}
+			continue
+		}
+
+		logf("课程 %s 读取已选课程失败: %v\n", t.kch, err)
+		wait := confirmBackoff
+		var throttled *throttledError
+		if errors.As(err, &throttled) {
+			wait = max(wait, throttled.delay)
+		}
+		if read == confirmReads || !sleepContext(ctx, wait) {
+			break
+		}
+	}
+
+	t.setConfirmed(false)
+	return true
+}
+
+// settleClaim decides what a worker does after the server claimed the course
+// is selected, either by a success answer or by an 已选 refusal. It reports
+// whether the worker stops and whether the course is held. A claim that the
+// list does not back up sends the worker back to retrying.
+func settleClaim(ctx context.Context, t *courseTarget, e *egress, outcome attemptOutcome) (done bool, held bool) {
+	if confirmSelection(ctx, t, e) {
+		return true, true
+	}
+	if ctx.Err() != nil {
+		return true, false
+	}
+
+	if outcome == outcomeSelected {
+		logf("⚠️  课程 %s 返回已选但不在已选课程中，继续选课\n", t.kch)
+		t.record(0, "返回已选但未在已选课程中找到", nil)
+		return false, false
+	}
+	logf("⚠️  课程 %s 返回成功但不在已选课程中，继续选课\n", t.kch)
+	t.record(0, "返回成功但未在已选课程中找到", nil)
+	return false, false
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// weekdayName converts the xq field ("1" to "7") to a Chinese weekday name
+func weekdayName(xq string) string {
+	switch xq {
+	case "1":
+		return "星期一"
+	case "2":
+		return "星期二"
+	case "3":
+		return "星期三"
+	case "4":
+		return "星期四"
+	case "5":
+		return "星期五"
+	case "6":
+		return "星期六"
+	case "7":
+		return "星期日"
+	}
+	return ""
+}
+
+// courseWarnings lists reasons why selecting a course may fail or be unwanted
+func courseWarnings(c Course) []string {
+	var warnings []string
+
+	if c.Sfkfxk != "" && c.Sfkfxk != "1" {
+		warnings = append(warnings, "该课程未开放选课")
+	}
+	if c.Sftk == "1" {
+		warnings = append(warnings, "该课程已停开")
+	}
+	if restriction := genderRestriction(c); restriction != "" {
+		warnings = append(warnings, "该课程有性别限制: "+restriction)
+	}
+	if c.Ctsm != "" {
+		warnings = append(warnings, "冲突说明: "+string(c.Ctsm))
+	}
+	return warnings
+}
+
+// genderRestriction returns the sex a course is limited to, or "" if it is
+// open to both. Schools fill the fields with placeholders such as 0, 无 or
+// 不限 when there is no limit, so only names or codes of one sex count.
+func genderRestriction(c Course) string {
+	name := strings.TrimSpace(string(c.Xbyqmc))
+	if strings.Contains(name, "不限") || (strings.Contains(name, "男") && strings.Contains(name, "女")) {
+		return ""
+	}
+	if strings.Contains(name, "男") || strings.Contains(name, "女") {
+		return name
+	}
+	if name != "" {
+		return ""
+	}
+	switch strings.TrimSpace(string(c.Xbyq)) {
+	case "1":
+		return "男"
+	case "2":
+		return "女"
+	}
+	return ""
+}
+
+// printCourseWarnings prints the warnings of a course, if any
+func printCourseWarnings(c Course) {
+	for _, warning := range courseWarnings(c) {
+		fmt.Printf("⚠️  课程 %s %s\n", c.Kch, warning)
+	}
+}
+
+// printCourseDetail shows every known field of a course
+func printCourseDetail(c Course) {
+	field := func(label string, value string) {
+		if strings.TrimSpace(value) == "" {
+			value = "-"
+		}
+		fmt.Printf("  %s %s\n", padWidth(label, 8), value)
+	}
+
+	fmt.Printf("\n课程 %s %s\n", c.Kch, c.Kcmc)
+	fmt.Println(strings.Repeat("-", 60))
+	field("选课ID", c.Jx0404id)
+	field("学年学期", string(c.Xnxq01id))
+	field("课堂名称", string(c.Ktmc))
+	field("课程性质", string(c.Kcxzmc))
+	field("通选类别", c.Szkcflmc)
+	field("学分", c.Xf.String())
+	field("总学时", c.Zxs.String())
+	field("开课单位", strings.TrimSpace(string(c.Dwmc)+" "+string(c.Kkdw)))
+	field("上课老师", c.Skls)
+	field("上课时间", c.Sksj)
+	field("上课地点", strings.TrimSpace(c.Xqmc+" "+c.Skdd))
+	field("容量", fmt.Sprintf("排课 %s / 限选 %s / 已选 %s / 剩余 %s", c.Pkrs, c.Xxrs, c.Xkrs, c.Syrs))
+
+	openState := "开放"
+	if c.Sfkfxk != "" && c.Sfkfxk != "1" {
+		openState = "未开放"
+	}
+	if c.Sftk == "1" {
+		openState += "，已停开"
+	}
+	field("选课状态", openState)
+	field("性别要求", string(c.Xbyqmc))
+	field("冲突说明", string(c.Ctsm))
+
+	for i, kkap := range c.KkapList {
+		field(fmt.Sprintf("安排%d", i+1), fmt.Sprintf("%s周 %s %s节 %s-%s %s %s",
+			kkap.Kkzc, weekdayName(kkap.Xq), kkap.Skjcmc, kkap.Kssj, kkap.Jssj, kkap.Jsmc, kkap.Jgxm))
+	}
+
+	field("课程简介", string(c.Kcjj))
+	field("教学大纲", string(c.JxdgFilename))
+	field("附件", string(c.FjFilename))
+
+	printCourseWarnings(c)
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+// TestGenderRestriction checks that placeholders of the 性别要求 fields do
+// not count as a restriction
+func TestGenderRestriction(t *testing.T) {
+	tests := []struct {
+		xbyq, xbyqmc string
+		want         string
+	}{
+		{"", "", ""},
+		{"0", "", ""},
+		{"", "无", ""},
+		{"0", "不限", ""},
+		{"1", "无", ""},
+		{"3", "男女不限", ""},
+		{"", "男女均可", ""},
+		{"1", "", "男"},
+		{"2", "", "女"},
+		{"1", "男", "男"},
+		{"", "仅限女生", "仅限女生"},
+	}
+	for _, tt := range tests {
+		c := Course{Xbyq: flexString(tt.xbyq), Xbyqmc: flexString(tt.xbyqmc)}
+		if got := genderRestriction(c); got != tt.want {
+			t.Errorf("%q/%q: got %q, want %q", tt.xbyq, tt.xbyqmc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Dashboard refresh interval and the number of recent log lines kept below the table
+const (
+	dashboardRefresh = 500 * time.Millisecond
+	dashboardEvents  = 6
+)
+
+// runStats counts the requests of a registration run across all workers
+type runStats struct {
+	requests atomic.Int64
+	relogins atomic.Int64
+	errors   atomic.Int64
+}
+
+// Global counters of the current run
+var stats runStats
+
+// dashboard redraws a status table of all targets in place while a run is in progress
+type dashboard struct {
+	targets []*courseTarget
+	started time.Time
+
+	mu        sync.Mutex
+	events    []string // Most recent log lines, oldest first
+	drawn     int      // Number of lines drawn by the previous frame
+	rates     []int64  // Request counts of the recent frames, for req/s
+	suspended bool     // Set while another part of the program uses the terminal
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Dashboard of the run in progress, nil when logging plainly
+var (
+	activeDashboard *dashboard
+	dashboardMu     sync.Mutex
+)
+
+// logf prints a log line, or adds it to the dashboard's recent lines while one is shown
+func logf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+
+	dashboardMu.Lock()
+	d := activeDashboard
+	dashboardMu.Unlock()
+	if d == nil {
+		fmt.Print(msg)
+		return
+	}
+	d.addEvent(msg)
+}
+
+// tracef prints a per-request log line. The dashboard already shows the
+// latest answer of every target, so these lines are dropped while it is shown.
+func tracef(format string, args ...any) {
+	dashboardMu.Lock()
+	d := activeDashboard
+	dashboardMu.Unlock()
+	if d == nil {
+		fmt.Printf(format, args...)
+	}
+}
+
+// startDashboard starts redrawing the targets in place if stdout is a terminal
+// that understands ANSI sequences, and returns nil otherwise
+func startDashboard(targets []*courseTarget) *dashboard {
+	if !ansiEnabled || !isTerminal(os.Stdout.Fd()) {
+		return nil
+	}
+
+	d := &dashboard{
+		targets: targets,
+		started: time.Now(),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	dashboardMu.Lock()
+	activeDashboard = d
+	dashboardMu.Unlock()
+
+	fmt.Print("\x1b[?25l") // Hide the cursor while redrawing
+	go d.run()
+	return d
+}
+
+// run redraws the dashboard until it is stopped
+func (d *dashboard) run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(dashboardRefresh)
+	defer ticker.Stop()
+
+	d.render()
+	for {
+		select {
+		case <-ticker.C:
+			d.render()
+		case <-d.stop:
+			d.render()
+			return
+		}
+	}
+}
+
+// close draws the final frame and switches back to plain logging
+func (d *dashboard) close() {
+	if d == nil {
+		return
+	}
+	close(d.stop)
+	<-d.done
+
+	dashboardMu.Lock()
+	activeDashboard = nil
+	dashboardMu.Unlock()
+
+	fmt.Print("\x1b[?25h")
+}
+
+// suspendDashboard stops redrawing the dashboard so that a prompt can use the
+// terminal, and returns a function that resumes it below the prompt
+func suspendDashboard() func() {
+	dashboardMu.Lock()
+	d := activeDashboard
+	dashboardMu.Unlock()
+	if d == nil {
+		return func() {}
+	}
+
+	d.mu.Lock()
+	d.suspended = true
+	d.mu.Unlock()
+	fmt.Print("\x1b[?25h")
+
+	return func() {
+		fmt.Print("\x1b[?25l")
+		d.mu.Lock()
+		d.suspended = false
+		d.drawn = 0
+		d.mu.Unlock()
+	}
+}
+
+// addEvent keeps the lines of a log message as the most recent events
+func (d *dashboard) addEvent(msg string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, line := range strings.Split(msg, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			d.events = append(d.events, time.Now().Format("15:04:05 ")+line)
+		}
+	}
+	if len(d.events) > dashboardEvents {
+		d.events = d.events[len(d.events)-dashboardEvents:]
+	}
+}
+
+// requestRate returns the requests per second over the last few frames
+func (d *dashboard) requestRate() float64 {
+	d.rates = append(d.rates, stats.requests.Load())
+	const window = 4
+	if len(d.rates) > window+1 {
+		d.rates = d.rates[len(d.rates)-window-1:]
+	}
+	if len(d.rates) < 2 {
+		return 0
+	}
+	frames := len(d.rates) - 1
+	return float64(d.rates[frames]-d.rates[0]) / (float64(frames) * dashboardRefresh.Seconds())
+}
+
+// render redraws the whole dashboard over the previous frame
+func (d *dashboard) render() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.suspended {
+		return
+	}
+
+	width := terminalWidth(os.Stdout.Fd())
+	if width <= 0 {
+		width = 120
+	}
+
+	lines := []string{
+		fmt.Sprintf("选课进行中 %v  请求 %.1f/s  共 %d 次  重新登录 %d  错误 %d  (Ctrl+C 停止)",
+			time.Since(d.started).Round(time.Second), d.requestRate(),
+			stats.requests.Load(), stats.relogins.Load(), stats.errors.Load()),
+		padWidth("课程编号", 10) + " " + padWidth("课程名称", 16) + " " + padWidth("状态", 6) + " " +
+			padWidth("尝试", 6) + " " + padWidth("延迟", 7) + " " + padWidth("上次正常", 8) + " 最后响应",
+	}
+
+	for _, t := range d.targets {
+		st := t.snapshot()
+		latency := "-"
+		if st.lastLatency > 0 {
+			latency = st.lastLatency.Round(time.Millisecond).String()
+		}
+		sinceOK := "-"
+		if !st.lastOK.IsZero() {
+			sinceOK = time.Since(st.lastOK).Round(time.Second).String()
+		}
+		lines = append(lines, padWidth(t.kch, 10)+" "+padWidth(t.name, 16)+" "+padWidth(st.state, 6)+" "+
+			padWidth(fmt.Sprint(st.attempts), 6)+" "+padWidth(latency, 7)+" "+padWidth(sinceOK, 8)+" "+
+			strings.ReplaceAll(st.lastMessage, "\n", " "))
+	}
+
+	if len(d.events) > 0 {
+		lines = append(lines, strings.Repeat("-", min(width-1, 80)))
+		lines = append(lines, d.events...)
+	}
+
+	var b strings.Builder
+	if d.drawn > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", d.drawn)
+	}
+	for _, line := range lines {
+		b.WriteString("\r" + truncateWidth(line, width-1) + "\x1b[K\n")
+	}
+	b.WriteString("\x1b[J")
+	fmt.Print(b.String())
+	d.drawn = len(lines)
+}
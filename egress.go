@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// egress is one outbound route to the school server (direct or through a
+// proxy). The WAF binds its session cookies to the client address, so every
+// egress keeps its own login session.
+type egress struct {
+	name        string
+	client      *http.Client // Follows redirects
+	loginClient *http.Client // Stops at the first redirect to capture login cookies
+
+	mu         sync.Mutex // Protects cookies and lastReauth
+	cookies    []*http.Cookie
+	lastReauth time.Time
+
+	loginMu sync.Mutex // Serializes relogins through this egress
+}
+
+// Global egresses: primary is used for interactive steps, workerEgresses for registration
+var primaryEgress *egress
+var workerEgresses []*egress
+
+// setupEgresses builds the primary egress and the optional proxy pool from the profile
+func setupEgresses() error {
+	primary, err := newEgress(profile.Proxy, profile.SourceAddr)
+	if err != nil {
+		return err
+	}
+	primaryEgress = primary
+	workerEgresses = []*egress{primary}
+
+	if len(profile.ProxyPool) > 0 {
+		workerEgresses = nil
+		for _, proxy := range profile.ProxyPool {
+			e, err := newEgress(proxy, profile.SourceAddr)
+			if err != nil {
+				return err
+			}
+			workerEgresses = append(workerEgresses, e)
+		}
+		fmt.Printf("选课请求将分散到 %d 个代理\n", len(workerEgresses))
+	}
+
+	return nil
+}
+
+// newEgress creates an egress for the given proxy URL and local source address.
+// An empty proxy uses HTTP_PROXY/HTTPS_PROXY/NO_PROXY from the environment,
+// "direct" disables proxying.
+func newEgress(proxy string, sourceAddr string) (*egress, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Keep enough idle connections for pre-warmed burst requests
+	transport.MaxIdleConnsPerHost = 64
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if sourceAddr != "" {
+		ip := net.ParseIP(sourceAddr)
+		if ip == nil {
+			return nil, fmt.Errorf("无效的源地址: %s", sourceAddr)
+		}
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	transport.DialContext = dialer.DialContext
+
+	name := "直连"
+	switch proxy {
+	case "":
+		transport.Proxy = http.ProxyFromEnvironment
+		name = "环境代理"
+	case "direct":
+		transport.Proxy = nil
+	default:
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("无效的代理地址 %s: %v", proxy, err)
+		}
+		switch strings.ToLower(proxyURL.Scheme) {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("不支持的代理协议 %q，仅支持 http、https、socks5", proxyURL.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+		name = proxyURL.Redacted()
+	}
+	if sourceAddr != "" {
+		name += " (源地址 " + sourceAddr + ")"
+	}
+
+	return &egress{
+		name:   name,
+		client: &http.Client{Transport: transport},
+		loginClient: &http.Client{
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
+
+// egressFor returns the egress a worker should use
+func egressFor(worker int) *egress {
+	return workerEgresses[worker%len(workerEgresses)]
+}
+
+// getCookies returns a copy of the current session cookies
+func (e *egress) getCookies() []*http.Cookie {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	cookies := make([]*http.Cookie, len(e.cookies))
+	copy(cookies, e.cookies)
+	return cookies
+}
+
+// setCookies replaces the session cookies after a login. The slice is
+// copied, since mergeCookies writes into it and the caller may keep using it.
+func (e *egress) setCookies(cookies []*http.Cookie) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.cookies = make([]*http.Cookie, len(cookies))
+	copy(e.cookies, cookies)
+	e.lastReauth = time.Now()
+}
+
+// mergeCookies updates session cookies from a response, e.g. a refreshed
+// HWWAFSESTIME, keeping every other cookie unchanged
+func (e *egress) mergeCookies(updates []*http.Cookie) {
+	if len(updates) == 0 {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, update := range updates {
+		replaced := false
+		for i, cookie := range e.cookies {
+			if cookie.Name == update.Name {
+				e.cookies[i] = update
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			e.cookies = append(e.cookies, update)
+		}
+	}
+}
+
+// sinceReauth returns how long ago this egress last logged in
+func (e *egress) sinceReauth() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return time.Since(e.lastReauth)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// TestSetCookiesCopies checks that merged cookies do not write into the
+// slice passed to setCookies
+func TestSetCookiesCopies(t *testing.T) {
+	e, err := newEgress("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := []*http.Cookie{{Name: "JSESSIONID", Value: "a"}, {Name: "HWWAFSESTIME", Value: "1"}}
+	e.setCookies(cookies)
+	e.mergeCookies([]*http.Cookie{{Name: "HWWAFSESTIME", Value: "2"}})
+
+	if cookies[1].Value != "1" {
+		t.Errorf("the caller's cookie was replaced with %s", cookies[1].Value)
+	}
+	if got := e.getCookies(); got[1].Value != "2" {
+		t.Errorf("the egress kept %s, want the merged cookie", got[1].Value)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// eventWriter receives one JSON object per line in --events=ndjson mode, nil otherwise
+var (
+	eventWriter io.Writer
+	eventMu     sync.Mutex
+)
+
+// setupEvents switches to the NDJSON event stream. Events keep the real
+// stdout and all human-readable output moves to stderr.
+func setupEvents(mode string) error {
+	switch mode {
+	case "":
+		return nil
+	case "ndjson":
+		eventWriter = os.Stdout
+		os.Stdout = os.Stderr
+		return nil
+	}
+	return fmt.Errorf("不支持的事件格式 %q，目前只支持 ndjson", mode)
+}
+
+// emitEvent writes an event with the given fields. Every event carries its
+// type in "event" and the local time in "time".
+func emitEvent(kind string, fields map[string]any) {
+	if eventWriter == nil {
+		return
+	}
+
+	event := map[string]any{
+		"event": kind,
+		"time":  time.Now().Format(time.RFC3339Nano),
+	}
+	for k, v := range fields {
+		event[k] = v
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		line, _ = json.Marshal(map[string]any{"event": "error", "error": err.Error()})
+	}
+
+	eventMu.Lock()
+	defer eventMu.Unlock()
+	eventWriter.Write(append(line, '\n'))
+}
+
+// errorText returns the message of err, or "" for nil, for event fields
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rows shown per section of the report
+const reportRows = 15
+
+// seatRecord is the seat count of one section at one check of the monitor,
+// stored as one line of the seat history file
+type seatRecord struct {
+	Time     time.Time `json:"time"`
+	Term     string    `json:"term"`
+	Session  string    `json:"session"`
+	Kch      string    `json:"kch"`
+	Kcmc     string    `json:"kcmc"`
+	Skls     string    `json:"skls"`
+	Jx0404id string    `json:"jx0404id"`
+	Syrs     int       `json:"syrs"` // 剩余量
+	Xkrs     int       `json:"xkrs"` // 已选人数
+	Pkrs     int       `json:"pkrs"` // 排课人数
+	Xxrs     int       `json:"xxrs"` // 限选人数
+}
+
+// seatHistoryPath returns the seat history file, kept next to the snapshots by default
+func seatHistoryPath() string {
+	if profile.HistoryFile != "" {
+		return profile.HistoryFile
+	}
+	return filepath.Join(snapshotDir(), "seats.jsonl")
+}
+
+// recordSeatHistory appends the seat counts of every section of a snapshot to
+// the history file. Sections without a remaining count are left out and other
+// unknown counts are stored as 0.
+func recordSeatHistory(snapshot *catalogSnapshot) error {
+	f, err := os.OpenFile(seatHistoryPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, c := range snapshot.Courses {
+		if !c.Syrs.known() {
+			continue
+		}
+		err := enc.Encode(seatRecord{
+			Time:     snapshot.Time,
+			Term:     snapshot.Term,
+			Session:  snapshot.Session,
+			Kch:      c.Kch,
+			Kcmc:     c.Kcmc,
+			Skls:     c.Skls,
+			Jx0404id: c.Jx0404id,
+			Syrs:     int(c.Syrs),
+			Xkrs:     max(0, int(c.Xkrs)),
+			Pkrs:     max(0, int(c.Pkrs)),
+			Xxrs:     max(0, int(c.Xxrs)),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// loadSeatHistory reads the history file, skipping lines that do not parse
+func loadSeatHistory(path string) ([]seatRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []seatRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r seatRecord
+		if json.Unmarshal(scanner.Bytes(), &r) == nil && r.Jx0404id != "" {
+			records = append(records, r)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// seatSeries is the history of one section in one session, oldest first
+type seatSeries []seatRecord
+
+// capacity returns the number of places of a section, preferring the 限选人数
+func (r seatRecord) capacity() int {
+	if r.Xxrs > 0 {
+		return r.Xxrs
+	}
+	return r.Pkrs
+}
+
+// runReport summarises the seat history of the sections matching every filter
+// word: the hours at which seats free up, the sections that filled fastest and
+// the fill ratios of the latest check.
+func runReport(filters []string) {
+	path := seatHistoryPath()
+	records, err := loadSeatHistory(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Println("还没有余量记录，先用 monitor 命令监控课程列表")
+			return
+		}
+		fmt.Printf("读取余量记录失败: %v\n", err)
+		return
+	}
+
+	series := make(map[string]seatSeries)
+	var keys []string
+	for _, r := range records {
+		text := strings.ToLower(strings.Join([]string{r.Kch, r.Kcmc, r.Skls, r.Jx0404id, r.Term, r.Session}, " "))
+		matched := true
+		for _, f := range filters {
+			if !strings.Contains(text, strings.ToLower(f)) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		key := r.Session + "\x00" + r.Jx0404id
+		if _, ok := series[key]; !ok {
+			keys = append(keys, key)
+		}
+		series[key] = append(series[key], r)
+	}
+	if len(series) == 0 {
+		fmt.Println("没有匹配的余量记录")
+		return
+	}
+	for _, key := range keys {
+		s := series[key]
+		sort.SliceStable(s, func(i, j int) bool { return s[i].Time.Before(s[j].Time) })
+	}
+
+	var first, last time.Time
+	for _, s := range series {
+		if first.IsZero() || s[0].Time.Before(first) {
+			first = s[0].Time
+		}
+		if s[len(s)-1].Time.After(last) {
+			last = s[len(s)-1].Time
+		}
+	}
+	fmt.Printf("\n余量记录 %s: %d 个教学班，%s 至 %s\n", path, len(series),
+		first.Local().Format("2006-01-02 15:04"), last.Local().Format("2006-01-02 15:04"))
+
+	printFreedHours(series)
+	printFastestFills(series, keys)
+	printFillRatios(series, keys)
+}
+
+// printFreedHours shows at which hours of the day seats were freed, counting
+// every rise of a section's remaining seats between two checks
+func printFreedHours(series map[string]seatSeries) {
+	events, seats := freedHours(series)
+	total := 0
+	for _, n := range events {
+		total += n
+	}
+
+	fmt.Println("\n名额释放时段:")
+	if total == 0 {
+		fmt.Println("记录中没有名额释放")
+		return
+	}
+
+	most := 0
+	for _, n := range seats {
+		most = max(most, n)
+	}
+	tbl := newTable(
+		tableColumn{title: "时段", min: 11},
+		tableColumn{title: "次数", min: 4},
+		tableColumn{title: "名额", min: 4},
+		tableColumn{title: "分布", min: 10, max: 30},
+	)
+	for hour := range seats {
+		if events[hour] == 0 {
+			continue
+		}
+		bar := strings.Repeat("█", max(1, seats[hour]*30/most))
+		tbl.addRow(fmt.Sprintf("%02d:00-%02d:00", hour, (hour+1)%24),
+			strconv.Itoa(events[hour]), strconv.Itoa(seats[hour]), bar)
+	}
+	tbl.render()
+}
+
+// freedHours counts, per local hour, how often seats freed up between two
+// checks and how many seats were freed
+func freedHours(series map[string]seatSeries) (events, seats [24]int) {
+	for _, s := range series {
+		for i := 1; i < len(s); i++ {
+			if gained := s[i].Syrs - s[i-1].Syrs; gained > 0 {
+				hour := s[i].Time.Local().Hour()
+				events[hour]++
+				seats[hour] += gained
+			}
+		}
+	}
+	return events, seats
+}
+
+// fillTime is how long a section took from its first free seat to full
+type fillTime struct {
+	first    seatRecord
+	seats    int
+	duration time.Duration
+}
+
+// printFastestFills lists the sections that went from free seats to full in
+// the shortest time. The precision is the interval of the monitor.
+func printFastestFills(series map[string]seatSeries, keys []string) {
+	fills := fastestFills(series, keys)
+
+	fmt.Println("\n满员最快的教学班:")
+	if len(fills) == 0 {
+		fmt.Println("记录中没有从有空位到满员的教学班")
+		return
+	}
+
+	tbl := newTable(
+		tableColumn{title: "课程编号", min: 8},
+		tableColumn{title: "课程名称", min: 8, max: 24},
+		tableColumn{title: "教师", min: 6, max: 12},
+		tableColumn{title: "选课会话", min: 8, max: 20},
+		tableColumn{title: "名额", min: 4},
+		tableColumn{title: "满员用时", min: 8},
+	)
+	for _, f := range fills[:min(len(fills), reportRows)] {
+		tbl.addRow(f.first.Kch, f.first.Kcmc, f.first.Skls, f.first.Session,
+			strconv.Itoa(f.seats), f.duration.Round(time.Second).String())
+	}
+	tbl.render()
+}
+
+// fastestFills returns, fastest first, how long each section took from its
+// first free seat to the next check that found it full
+func fastestFills(series map[string]seatSeries, keys []string) []fillTime {
+	var fills []fillTime
+	for _, key := range keys {
+		s := series[key]
+		for i, r := range s {
+			if r.Syrs <= 0 {
+				continue
+			}
+			for _, later := range s[i+1:] {
+				if later.Syrs <= 0 {
+					fills = append(fills, fillTime{first: r, seats: r.Syrs, duration: later.Time.Sub(r.Time)})
+					break
+				}
+			}
+			break
+		}
+	}
+	sort.SliceStable(fills, func(i, j int) bool { return fills[i].duration < fills[j].duration })
+	return fills
+}
+
+// fillRatio is the share of places taken in a section at its latest check
+type fillRatio struct {
+	latest   seatRecord
+	taken    int
+	capacity int
+}
+
+// printFillRatios lists the sections by the share of places taken at their
+// latest check
+func printFillRatios(series map[string]seatSeries, keys []string) {
+	ratios := fillRatios(series, keys)
+
+	fmt.Println("\n选课比例最高的教学班:")
+	if len(ratios) == 0 {
+		fmt.Println("记录中没有容量信息")
+		return
+	}
+
+	tbl := newTable(
+		tableColumn{title: "课程编号", min: 8},
+		tableColumn{title: "课程名称", min: 8, max: 24},
+		tableColumn{title: "教师", min: 6, max: 12},
+		tableColumn{title: "已选/容量", min: 8},
+		tableColumn{title: "比例", min: 6, color: fillRatioColor},
+	)
+	for _, r := range ratios[:min(len(ratios), reportRows)] {
+		tbl.addRow(r.latest.Kch, r.latest.Kcmc, r.latest.Skls,
+			fmt.Sprintf("%d/%d", r.taken, r.capacity),
+			fmt.Sprintf("%.0f%%", float64(r.taken)*100/float64(r.capacity)))
+	}
+	tbl.render()
+}
+
+// fillRatios returns the fill ratios of the sections with a known capacity,
+// fullest first, counting from the 已选人数 or, if missing, the remaining seats
+func fillRatios(series map[string]seatSeries, keys []string) []fillRatio {
+	var ratios []fillRatio
+	for _, key := range keys {
+		s := series[key]
+		latest := s[len(s)-1]
+		capacity := latest.capacity()
+		if capacity <= 0 {
+			continue
+		}
+		taken := latest.Xkrs
+		if taken == 0 && latest.Syrs < capacity {
+			taken = capacity - latest.Syrs
+		}
+		ratios = append(ratios, fillRatio{latest: latest, taken: taken, capacity: capacity})
+	}
+	sort.SliceStable(ratios, func(i, j int) bool {
+		return ratios[i].taken*ratios[j].capacity > ratios[j].taken*ratios[i].capacity
+	})
+	return ratios
+}
+
+// fillRatioColor shows full sections in red
+func fillRatioColor(cell string) string {
+	if n, err := strconv.Atoi(strings.TrimSuffix(cell, "%")); err == nil && n >= 100 {
+		return colorRed
+	}
+	return ""
+}
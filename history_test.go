@@ -0,0 +1,156 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// checkAt returns a local time on the same day at hour:minute
+func checkAt(hour, minute int) time.Time {
+	return time.Date(2026, 9, 1, hour, minute, 0, 0, time.Local)
+}
+
+// seatPoints builds the history of one section from its remaining seats at each time
+func seatPoints(id string, points ...any) seatSeries {
+	var s seatSeries
+	for i := 0; i < len(points); i += 2 {
+		s = append(s, seatRecord{Jx0404id: id, Kch: "K" + id, Time: points[i].(time.Time), Syrs: points[i+1].(int)})
+	}
+	return s
+}
+
+// TestFreedHours checks that rises of the remaining seats are counted in the
+// hour of the check that saw them
+func TestFreedHours(t *testing.T) {
+	tests := []struct {
+		name   string
+		series map[string]seatSeries
+		events map[int]int
+		seats  map[int]int
+	}{
+		{
+			name:   "no rise",
+			series: map[string]seatSeries{"A": seatPoints("A", checkAt(8, 0), 5, checkAt(8, 10), 3, checkAt(9, 0), 3)},
+		},
+		{
+			name:   "rise counted at the later check",
+			series: map[string]seatSeries{"A": seatPoints("A", checkAt(8, 55), 0, checkAt(9, 5), 2)},
+			events: map[int]int{9: 1},
+			seats:  map[int]int{9: 2},
+		},
+		{
+			name: "rises of several sections in one hour",
+			series: map[string]seatSeries{
+				"A": seatPoints("A", checkAt(13, 0), 0, checkAt(13, 10), 1, checkAt(13, 20), 0, checkAt(13, 30), 3),
+				"B": seatPoints("B", checkAt(13, 0), 4, checkAt(13, 40), 5, checkAt(22, 0), 0, checkAt(23, 0), 2),
+			},
+			events: map[int]int{13: 3, 23: 1},
+			seats:  map[int]int{13: 5, 23: 2},
+		},
+	}
+	for _, tt := range tests {
+		events, seats := freedHours(tt.series)
+		for hour := range events {
+			if events[hour] != tt.events[hour] || seats[hour] != tt.seats[hour] {
+				t.Errorf("%s: hour %d has %d events and %d seats, want %d and %d",
+					tt.name, hour, events[hour], seats[hour], tt.events[hour], tt.seats[hour])
+			}
+		}
+	}
+}
+
+// TestFastestFills checks the time from the first free seat to the next
+// check that found the section full
+func TestFastestFills(t *testing.T) {
+	tests := []struct {
+		name   string
+		series map[string]seatSeries
+		want   []string // Sections, fastest first
+		times  []time.Duration
+		seats  []int
+	}{
+		{
+			name:   "never full",
+			series: map[string]seatSeries{"A": seatPoints("A", checkAt(8, 0), 5, checkAt(8, 10), 1)},
+		},
+		{
+			name:   "never free",
+			series: map[string]seatSeries{"A": seatPoints("A", checkAt(8, 0), 0, checkAt(8, 10), 0)},
+		},
+		{
+			name: "counted from the first free seat",
+			series: map[string]seatSeries{
+				"A": seatPoints("A", checkAt(8, 0), 0, checkAt(8, 10), 4, checkAt(8, 20), 2, checkAt(8, 40), 0),
+				"B": seatPoints("B", checkAt(8, 0), 3, checkAt(8, 5), 0, checkAt(8, 10), 6, checkAt(8, 11), 0),
+			},
+			want:  []string{"B", "A"},
+			times: []time.Duration{5 * time.Minute, 30 * time.Minute},
+			seats: []int{3, 4},
+		},
+	}
+	for _, tt := range tests {
+		var keys []string
+		for key := range tt.series {
+			keys = append(keys, key)
+		}
+		fills := fastestFills(tt.series, keys)
+		if len(fills) != len(tt.want) {
+			t.Errorf("%s: got %d fills, want %d", tt.name, len(fills), len(tt.want))
+			continue
+		}
+		for i, f := range fills {
+			if f.first.Jx0404id != tt.want[i] || f.duration != tt.times[i] || f.seats != tt.seats[i] {
+				t.Errorf("%s: fill %d is %s with %d seats in %v, want %s with %d in %v",
+					tt.name, i, f.first.Jx0404id, f.seats, f.duration, tt.want[i], tt.seats[i], tt.times[i])
+			}
+		}
+	}
+}
+
+// TestFillRatios checks the taken places of the latest check, falling back
+// to the capacity minus the remaining seats when 已选人数 is missing
+func TestFillRatios(t *testing.T) {
+	record := func(id string, syrs, xkrs, pkrs, xxrs int) seatSeries {
+		return seatSeries{{Jx0404id: id, Time: checkAt(8, 0), Syrs: syrs, Xkrs: xkrs, Pkrs: pkrs, Xxrs: xxrs}}
+	}
+	tests := []struct {
+		name     string
+		series   seatSeries
+		taken    int
+		capacity int
+		skipped  bool
+	}{
+		{"已选人数", record("A", 10, 30, 40, 0), 30, 40, false},
+		{"限选人数 before 排课人数", record("A", 5, 45, 40, 50), 45, 50, false},
+		{"missing 已选人数", record("A", 15, 0, 60, 0), 45, 60, false},
+		{"missing 已选人数 and empty", record("A", 60, 0, 60, 0), 0, 60, false},
+		{"no capacity", record("A", 3, 7, 0, 0), 0, 0, true},
+	}
+	for _, tt := range tests {
+		ratios := fillRatios(map[string]seatSeries{"A": tt.series}, []string{"A"})
+		if tt.skipped {
+			if len(ratios) != 0 {
+				t.Errorf("%s: expected no ratio, got %+v", tt.name, ratios)
+			}
+			continue
+		}
+		if len(ratios) != 1 || ratios[0].taken != tt.taken || ratios[0].capacity != tt.capacity {
+			t.Errorf("%s: got %+v, want %d/%d", tt.name, ratios, tt.taken, tt.capacity)
+		}
+	}
+
+	// Fullest first
+	all := map[string]seatSeries{
+		"A": record("A", 30, 10, 40, 0),
+		"B": record("B", 0, 0, 20, 0),
+		"C": record("C", 10, 0, 40, 0),
+	}
+	ratios := fillRatios(all, []string{"A", "B", "C"})
+	var order []string
+	for _, r := range ratios {
+		order = append(order, r.latest.Jx0404id)
+	}
+	if len(order) != 3 || order[0] != "B" || order[1] != "C" || order[2] != "A" {
+		t.Errorf("expected B, C, A, got %v", order)
+	}
+}
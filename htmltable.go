@@ -0,0 +1,346 @@
+package main
+
+import (
+	"html"
+	"strings"
+)
+
+// The pages of the QZ system are hand-written HTML with unclosed cells,
+// comments, nested tags and attributes that change between schools. The
+// tokenizer below understands just enough HTML to pull tables out of them
+// without depending on the exact markup.
+
+// htmlTokenKind is the kind of an HTML token
+type htmlTokenKind int
+
+const (
+	htmlText     htmlTokenKind = iota // Text between tags, entities decoded
+	htmlStartTag                      // <tag ...> or <tag .../>
+	htmlEndTag                        // </tag>
+)
+
+// htmlToken is one tag or run of text
+type htmlToken struct {
+	kind  htmlTokenKind
+	name  string            // Lowercase tag name
+	attrs map[string]string // Attributes with lowercase names and decoded values
+	text  string
+}
+
+// Elements whose content is raw text rather than markup
+var rawTextElements = map[string]bool{"script": true, "style": true, "textarea": true}
+
+// tokenizeHTML splits a document into tags and text, dropping comments,
+// doctypes and processing instructions
+func tokenizeHTML(src string) []htmlToken {
+	var tokens []htmlToken
+	text := func(s string) {
+		if s != "" {
+			tokens = append(tokens, htmlToken{kind: htmlText, text: html.UnescapeString(s)})
+		}
+	}
+
+	for len(src) > 0 {
+		lt := strings.IndexByte(src, '<')
+		if lt < 0 {
+			text(src)
+			break
+		}
+		text(src[:lt])
+		src = src[lt:]
+
+		switch {
+		case strings.HasPrefix(src, "<!--"):
+			end := strings.Index(src[4:], "-->")
+			if end < 0 {
+				return tokens
+			}
+			src = src[4+end+3:]
+		case strings.HasPrefix(src, "<!") || strings.HasPrefix(src, "<?"):
+			end := strings.IndexByte(src, '>')
+			if end < 0 {
+				return tokens
+			}
+			src = src[end+1:]
+		case strings.HasPrefix(src, "</"):
+			end := strings.IndexByte(src, '>')
+			if end < 0 {
+				return tokens
+			}
+			name := strings.ToLower(strings.TrimSpace(src[2:end]))
+			if i := strings.IndexAny(name, " \t\r\n"); i >= 0 {
+				name = name[:i]
+			}
+			tokens = append(tokens, htmlToken{kind: htmlEndTag, name: name})
+			src = src[end+1:]
+		case len(src) > 1 && isASCIILetter(src[1]):
+			tok, rest := parseStartTag(src)
+			tokens = append(tokens, tok)
+			src = rest
+
+			// Keep script and style content out of the text
+			if rawTextElements[tok.name] {
+				end := strings.Index(strings.ToLower(src), "</"+tok.name)
+				if end < 0 {
+					return tokens
+				}
+				src = src[end:]
+			}
+		default:
+			text("<")
+			src = src[1:]
+		}
+	}
+	return tokens
+}
+
+// parseStartTag parses "<name attr=value ...>" at the start of src and returns the rest
+func parseStartTag(src string) (htmlToken, string) {
+	tok := htmlToken{kind: htmlStartTag, attrs: make(map[string]string)}
+
+	i := 1
+	for i < len(src) && !isHTMLSpace(src[i]) && src[i] != '>' && src[i] != '/' {
+		i++
+	}
+	tok.name = strings.ToLower(src[1:i])
+
+	for i < len(src) {
+		for i < len(src) && (isHTMLSpace(src[i]) || src[i] == '/') {
+			i++
+		}
+		if i >= len(src) {
+			break
+		}
+		if src[i] == '>' {
+			return tok, src[i+1:]
+		}
+
+		start := i
+		for i < len(src) && !isHTMLSpace(src[i]) && src[i] != '=' && src[i] != '>' && src[i] != '/' {
+			i++
+		}
+		name := strings.ToLower(src[start:i])
+
+		for i < len(src) && isHTMLSpace(src[i]) {
+			i++
+		}
+		value := ""
+		if i < len(src) && src[i] == '=' {
+			i++
+			for i < len(src) && isHTMLSpace(src[i]) {
+				i++
+			}
+			if i < len(src) && (src[i] == '"' || src[i] == '\'') {
+				quote := src[i]
+				end := strings.IndexByte(src[i+1:], quote)
+				if end < 0 {
+					value = src[i+1:]
+					i = len(src)
+				} else {
+					value = src[i+1 : i+1+end]
+					i += end + 2
+				}
+			} else {
+				start := i
+				for i < len(src) && !isHTMLSpace(src[i]) && src[i] != '>' {
+					i++
+				}
+				value = src[start:i]
+			}
+		}
+		if name != "" {
+			tok.attrs[name] = html.UnescapeString(value)
+		}
+	}
+	return tok, ""
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// htmlLink is an <a> element inside a table cell
+type htmlLink struct {
+	Href    string
+	Onclick string
+	Text    string
+}
+
+// htmlCell is a td or th element
+type htmlCell struct {
+	Header bool
+	Text   string // Visible text with whitespace collapsed
+	Links  []htmlLink
+}
+
+// htmlRow is a tr element
+type htmlRow struct {
+	Attrs map[string]string
+	Cells []htmlCell
+}
+
+// htmlTable is a table element. Rows of nested tables belong to the nested
+// table only.
+type htmlTable struct {
+	Attrs map[string]string
+	Rows  []htmlRow
+}
+
+// tableBuilder collects the rows of one open table
+type tableBuilder struct {
+	index    int // Position in the result
+	table    htmlTable
+	row      *htmlRow
+	cell     *htmlCell
+	cellText strings.Builder
+	link     *htmlLink
+	linkText strings.Builder
+}
+
+// closeLink finishes the open link of the current cell
+func (b *tableBuilder) closeLink() {
+	if b.link == nil || b.cell == nil {
+		b.link = nil
+		return
+	}
+	b.link.Text = collapseSpace(b.linkText.String())
+	b.cell.Links = append(b.cell.Links, *b.link)
+	b.link = nil
+	b.linkText.Reset()
+}
+
+// closeCell finishes the open cell
+func (b *tableBuilder) closeCell() {
+	b.closeLink()
+	if b.cell == nil {
+		return
+	}
+	if b.row == nil {
+		b.row = &htmlRow{}
+	}
+	b.cell.Text = collapseSpace(b.cellText.String())
+	b.row.Cells = append(b.row.Cells, *b.cell)
+	b.cell = nil
+	b.cellText.Reset()
+}
+
+// closeRow finishes the open row
+func (b *tableBuilder) closeRow() {
+	b.closeCell()
+	if b.row != nil {
+		b.table.Rows = append(b.table.Rows, *b.row)
+		b.row = nil
+	}
+}
+
+// extractTables returns every table of a document in the order they start.
+// Missing </td>, </tr> and </a> tags are tolerated.
+func extractTables(tokens []htmlToken) []htmlTable {
+	var tables []htmlTable
+	var stack []*tableBuilder
+
+	for _, tok := range tokens {
+		var b *tableBuilder
+		if len(stack) > 0 {
+			b = stack[len(stack)-1]
+		}
+
+		switch tok.kind {
+		case htmlText:
+			if b != nil && b.cell != nil {
+				b.cellText.WriteString(tok.text)
+				if b.link != nil {
+					b.linkText.WriteString(tok.text)
+				}
+			}
+		case htmlStartTag:
+			switch tok.name {
+			case "table":
+				tables = append(tables, htmlTable{})
+				stack = append(stack, &tableBuilder{index: len(tables) - 1, table: htmlTable{Attrs: tok.attrs}})
+			case "tr":
+				if b != nil {
+					b.closeRow()
+					b.row = &htmlRow{Attrs: tok.attrs}
+				}
+			case "td", "th":
+				if b != nil {
+					b.closeCell()
+					b.cell = &htmlCell{Header: tok.name == "th"}
+				}
+			case "a":
+				if b != nil && b.cell != nil {
+					b.closeLink()
+					b.link = &htmlLink{Href: tok.attrs["href"], Onclick: tok.attrs["onclick"]}
+				}
+			case "br", "p", "div":
+				if b != nil && b.cell != nil {
+					b.cellText.WriteString(" ")
+				}
+			}
+		case htmlEndTag:
+			if b == nil {
+				continue
+			}
+			switch tok.name {
+			case "table":
+				b.closeRow()
+				tables[b.index] = b.table
+				stack = stack[:len(stack)-1]
+			case "tr":
+				b.closeRow()
+			case "td", "th":
+				b.closeCell()
+			case "a":
+				b.closeLink()
+			}
+		}
+	}
+
+	// Tables left open at the end of a truncated document
+	for _, b := range stack {
+		b.closeRow()
+		tables[b.index] = b.table
+	}
+	return tables
+}
+
+// extractLinks returns every <a> element of a document, inside tables or not
+func extractLinks(tokens []htmlToken) []htmlLink {
+	var links []htmlLink
+	var open *htmlLink
+	var text strings.Builder
+
+	closeLink := func() {
+		if open != nil {
+			open.Text = collapseSpace(text.String())
+			links = append(links, *open)
+			open = nil
+			text.Reset()
+		}
+	}
+
+	for _, tok := range tokens {
+		switch {
+		case tok.kind == htmlStartTag && tok.name == "a":
+			closeLink()
+			open = &htmlLink{Href: tok.attrs["href"], Onclick: tok.attrs["onclick"]}
+		case tok.kind == htmlEndTag && tok.name == "a":
+			closeLink()
+		case tok.kind == htmlText && open != nil:
+			text.WriteString(tok.text)
+		}
+	}
+	closeLink()
+	return links
+}
+
+// collapseSpace trims a string and replaces runs of whitespace, including
+// non-breaking spaces, with a single space
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// icsTimeFormat is the UTC DATE-TIME form defined by RFC 5545
+const icsTimeFormat = "20060102T150405Z"
+
+// exportICS writes the timetable of the given courses to an .ics file,
+// replacing the file of an earlier run
+func exportICS(path string, courses []Course) error {
+	termStart, err := termStartDate()
+	if err != nil {
+		return err
+	}
+
+	content, skipped := buildICS(courses, termStart, time.Now())
+	for _, msg := range skipped {
+		fmt.Printf("导出日历时跳过: %s\n", msg)
+	}
+
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// timetableCourses returns the sections to put in the calendar: the courses
+// selected in this run and every other section on the 已选课程 list that the
+// catalog describes. The file is rewritten on every run, so it covers the
+// whole timetable rather than only the latest run. Held sections missing from
+// the catalog have no arrangements to export and are left out.
+func timetableCourses(selected []Course) []Course {
+	courses := append([]Course(nil), selected...)
+	seen := make(map[string]bool)
+	for _, c := range courses {
+		seen[c.Jx0404id] = true
+	}
+
+	held, err := fetchSelectedCourses(primaryEgress)
+	if err != nil {
+		fmt.Printf("读取已选课程失败，日历只包含本次选上的课程: %v\n", err)
+		return courses
+	}
+	for _, h := range held {
+		if seen[h.Jx0404id] {
+			continue
+		}
+		seen[h.Jx0404id] = true
+		found := findCourses(h.Jx0404id)
+		if len(found) != 1 {
+			fmt.Printf("导出日历时跳过: %s %s 不在当前课程列表中\n", h.Kch, h.Kcmc)
+			continue
+		}
+		courses = append(courses, found[0])
+	}
+	return courses
+}
+
+// buildICS renders one VEVENT series per course arrangement and returns the
+// calendar text together with a description of every skipped arrangement
+func buildICS(courses []Course, termStart time.Time, now time.Time) (string, []string) {
+	var b strings.Builder
+	var skipped []string
+
+	writeICSLine(&b, "BEGIN:VCALENDAR")
+	writeICSLine(&b, "VERSION:2.0")
+	writeICSLine(&b, "PRODID:-//51HzOuO//qzjwxt_xk//CN")
+	writeICSLine(&b, "CALSCALE:GREGORIAN")
+	writeICSLine(&b, "X-WR-CALNAME:选课课表")
+
+	stamp := now.UTC().Format(icsTimeFormat)
+
+	for _, course := range courses {
+		for i, kkap := range course.KkapList {
+			starts, duration, err := arrangementOccurrences(kkap, termStart)
+			if err != nil {
+				skipped = append(skipped, fmt.Sprintf("%s %s 第%d个安排: %v", course.Kch, course.Kcmc, i+1, err))
+				continue
+			}
+
+			teacher := kkap.Jgxm
+			if teacher == "" {
+				teacher = course.Skls
+			}
+			room := kkap.Jsmc
+			if room == "" {
+				room = course.Skdd
+			}
+
+			writeICSLine(&b, "BEGIN:VEVENT")
+			writeICSLine(&b, fmt.Sprintf("UID:%s-%d@qzjwxt_xk", course.Jx0404id, i+1))
+			writeICSLine(&b, "DTSTAMP:"+stamp)
+			writeICSLine(&b, "DTSTART:"+starts[0].UTC().Format(icsTimeFormat))
+			writeICSLine(&b, "DTEND:"+starts[0].Add(duration).UTC().Format(icsTimeFormat))
+			if len(starts) > 1 {
+				var rdates []string
+				for _, start := range starts[1:] {
+					rdates = append(rdates, start.UTC().Format(icsTimeFormat))
+				}
+				writeICSLine(&b, "RDATE:"+strings.Join(rdates, ","))
+			}
+			writeICSLine(&b, "SUMMARY:"+escapeICSText(course.Kcmc))
+			writeICSLine(&b, "LOCATION:"+escapeICSText(strings.TrimSpace(course.Xqmc+" "+room)))
+			writeICSLine(&b, "DESCRIPTION:"+escapeICSText(fmt.Sprintf("课程编号: %s\n教师: %s\n节次: %s\n周次: %s",
+				course.Kch, teacher, kkap.Skjcmc, kkap.Kkzc)))
+			writeICSLine(&b, "END:VEVENT")
+		}
+	}
+
+	writeICSLine(&b, "END:VCALENDAR")
+	return b.String(), skipped
+}
+
+// arrangementOccurrences returns the start time of every week an arrangement
+// takes place in, sorted ascending, and the length of a single meeting
+func arrangementOccurrences(kkap KkapInfo, termStart time.Time) ([]time.Time, time.Duration, error) {
+	weekday, err := strconv.Atoi(kkap.Xq)
+	if err != nil || weekday < 1 || weekday > 7 {
+		return nil, 0, fmt.Errorf("无效的星期 %q", kkap.Xq)
+	}
+
+	startHour, startMinute, ok := parseClock(kkap.Kssj)
+	if !ok {
+		return nil, 0, fmt.Errorf("无效的开始时间 %q", kkap.Kssj)
+	}
+	endHour, endMinute, ok := parseClock(kkap.Jssj)
+	if !ok {
+		return nil, 0, fmt.Errorf("无效的结束时间 %q", kkap.Jssj)
+	}
+
+	duration := time.Duration((endHour*60+endMinute)-(startHour*60+startMinute)) * time.Minute
+	if duration <= 0 {
+		return nil, 0, fmt.Errorf("结束时间 %s 早于开始时间 %s", kkap.Jssj, kkap.Kssj)
+	}
+
+	weeks := arrangementWeeks(kkap)
+	if len(weeks) == 0 {
+		return nil, 0, fmt.Errorf("没有上课周次")
+	}
+
+	var starts []time.Time
+	for _, week := range weeks {
+		day := termStart.AddDate(0, 0, (week-1)*7+(weekday-1))
+		starts = append(starts, time.Date(day.Year(), day.Month(), day.Day(),
+			startHour, startMinute, 0, 0, termStart.Location()))
+	}
+
+	return starts, duration, nil
+}
+
+// arrangementWeeks returns the sorted, de-duplicated teaching weeks of an
+// arrangement, preferring skzcList and falling back to the kkzc text
+func arrangementWeeks(kkap KkapInfo) []int {
+	seen := make(map[int]struct{})
+	for _, w := range kkap.SkzcList {
+		if n, err := strconv.Atoi(strings.TrimSpace(w)); err == nil && n > 0 {
+			seen[n] = struct{}{}
+		}
+	}
+	if len(seen) == 0 {
+		for _, n := range parseWeekRanges(kkap.Kkzc) {
+			seen[n] = struct{}{}
+		}
+	}
+
+	weeks := make([]int, 0, len(seen))
+	for n := range seen {
+		weeks = append(weeks, n)
+	}
+	sort.Ints(weeks)
+	return weeks
+}
+
+// weekRangePattern matches "3" or "2-17" inside a kkzc string
+var weekRangePattern = regexp.MustCompile(`(\d+)(?:\s*-\s*(\d+))?`)
+
+// parseWeekRanges expands kkzc strings such as "2-17" or "1-8,10-16(双)"
+func parseWeekRanges(kkzc string) []int {
+	var weeks []int
+	for _, part := range strings.Split(kkzc, ",") {
+		odd := strings.Contains(part, "单")
+		even := strings.Contains(part, "双")
+
+		match := weekRangePattern.FindStringSubmatch(part)
+		if match == nil {
+			continue
+		}
+		from, _ := strconv.Atoi(match[1])
+		to := from
+		if match[2] != "" {
+			to, _ = strconv.Atoi(match[2])
+		}
+
+		for n := from; n <= to; n++ {
+			if (odd && n%2 == 0) || (even && n%2 == 1) {
+				continue
+			}
+			weeks = append(weeks, n)
+		}
+	}
+	return weeks
+}
+
+// parseClock parses "19:10" or "1910" into hour and minute
+func parseClock(s string) (int, int, bool) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ":", "")
+	if len(s) == 3 {
+		s = "0" + s
+	}
+	if len(s) != 4 {
+		return 0, 0, false
+	}
+
+	hour, err1 := strconv.Atoi(s[:2])
+	minute, err2 := strconv.Atoi(s[2:])
+	if err1 != nil || err2 != nil || hour > 23 || minute > 59 {
+		return 0, 0, false
+	}
+	return hour, minute, true
+}
+
+// escapeICSText escapes a TEXT property value as required by RFC 5545
+func escapeICSText(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return replacer.Replace(s)
+}
+
+// writeICSLine writes a content line, folding it at 75 octets without
+// splitting a multi-byte UTF-8 character
+func writeICSLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isUTF8Start(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts toward the limit
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+// isUTF8Start reports whether c begins a UTF-8 encoded character
+func isUTF8Start(c byte) bool {
+	return c&0xC0 != 0x80
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBuildICS checks the events of a course, the skipped arrangements and
+// the folding of long lines
+func TestBuildICS(t *testing.T) {
+	termStart := time.Date(2025, 9, 1, 0, 0, 0, 0, chinaTime)
+	now := time.Date(2025, 8, 20, 12, 0, 0, 0, time.UTC)
+	courses := []Course{{
+		Kch:      "GX0012",
+		Kcmc:     "中国传统文化, 经典; 导读",
+		Jx0404id: "202420252001234",
+		Skls:     "张老师",
+		Xqmc:     "南校区",
+		KkapList: []KkapInfo{
+			{Xq: "2", Kssj: "08:00", Jssj: "09:40", Kkzc: "1-3", Jsmc: "A101", Skjcmc: "1-2节"},
+			{Xq: "8", Kssj: "08:00", Jssj: "09:40", Kkzc: "1"},
+			{Xq: "4", Kssj: "1910", Jssj: "2045", SkzcList: []string{"2", "4"}, Jgxm: "李老师", Jsmc: "B202"},
+		},
+	}}
+
+	content, skipped := buildICS(courses, termStart, now)
+	if len(skipped) != 1 || !strings.Contains(skipped[0], "第2个安排") {
+		t.Errorf("expected the second arrangement to be skipped, got %v", skipped)
+	}
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:202420252001234-1@qzjwxt_xk\r\n",
+		"DTSTAMP:20250820T120000Z\r\n",
+		// Tuesday of week 1, 08:00 in China is 00:00 UTC
+		"DTSTART:20250902T000000Z\r\n",
+		"DTEND:20250902T014000Z\r\n",
+		"RDATE:20250909T000000Z,20250916T000000Z\r\n",
+		"SUMMARY:中国传统文化\\, 经典\\; 导读\r\n",
+		"LOCATION:南校区 A101\r\n",
+		"UID:202420252001234-3@qzjwxt_xk\r\n",
+		// Thursday of week 2, 19:10 in China
+		"DTSTART:20250911T111000Z\r\n",
+		"RDATE:20250925T111000Z\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("calendar is missing %q", want)
+		}
+	}
+	if strings.Contains(content, "-2@qzjwxt_xk") {
+		t.Error("the invalid arrangement should not produce an event")
+	}
+
+	for _, line := range strings.Split(content, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+	}
+}
+
+// TestParseWeekRanges expands the kkzc forms the catalog uses
+func TestParseWeekRanges(t *testing.T) {
+	tests := []struct {
+		kkzc string
+		want string
+	}{
+		{"2-5", "[2 3 4 5]"},
+		{"1-8,10", "[1 2 3 4 5 6 7 8 10]"},
+		{"1-6(单)", "[1 3 5]"},
+		{"1-6(双)", "[2 4 6]"},
+		{"", "[]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(parseWeekRanges(tt.kkzc)); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.kkzc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// The QZ backend is inconsistent about JSON types: the same field may arrive
+// as 60, "60", "", or null depending on the school and the endpoint. The
+// types below accept all of these forms.
+
+// flexInt is a count that may be encoded as a number or a string
+type flexInt int
+
+// unknownCount is a count the server left empty or sent as text such as 不限
+const unknownCount flexInt = -1
+
+// UnmarshalJSON accepts numbers and numeric strings. Empty strings, null and
+// text that is not a number decode as unknownCount.
+func (n *flexInt) UnmarshalJSON(data []byte) error {
+	f, ok := parseFlexNumber(data)
+	if !ok {
+		*n = unknownCount
+		return nil
+	}
+	*n = flexInt(f)
+	return nil
+}
+
+// known reports whether the server gave the count as a number
+func (n flexInt) known() bool {
+	return n != unknownCount
+}
+
+// String formats the integer for display, "-" if it is unknown
+func (n flexInt) String() string {
+	if !n.known() {
+		return "-"
+	}
+	return strconv.Itoa(int(n))
+}
+
+// flexFloat is a decimal number that may be encoded as a number or a string
+type flexFloat float64
+
+// UnmarshalJSON accepts numbers and numeric strings. Anything else decodes as 0.
+func (f *flexFloat) UnmarshalJSON(data []byte) error {
+	v, _ := parseFlexNumber(data)
+	*f = flexFloat(v)
+	return nil
+}
+
+// String formats the number without trailing zeros, e.g. "2" or "1.5"
+func (f flexFloat) String() string {
+	return strconv.FormatFloat(float64(f), 'f', -1, 64)
+}
+
+// flexString is a string that may be encoded as a string, a number or a boolean
+type flexString string
+
+// UnmarshalJSON accepts strings, numbers, booleans and null
+func (s *flexString) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*s = ""
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var v string
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		*s = flexString(v)
+		return nil
+	}
+
+	*s = flexString(data)
+	return nil
+}
+
+// parseFlexNumber decodes a JSON number or a quoted number and reports false
+// for "", null and anything else that is not a number
+func parseFlexNumber(data []byte) (float64, bool) {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return 0, false
+	}
+
+	text := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return 0, false
+		}
+	}
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestFlexIntUnmarshal decodes the forms the backend uses for counts
+func TestFlexIntUnmarshal(t *testing.T) {
+	tests := []struct {
+		json string
+		want flexInt
+	}{
+		{`"12"`, 12},
+		{`12`, 12},
+		{`"12.0"`, 12},
+		{`" 12 "`, 12},
+		{`0`, 0},
+		{`"0"`, 0},
+		{`""`, unknownCount},
+		{`null`, unknownCount},
+		{`"不限"`, unknownCount},
+	}
+	for _, tt := range tests {
+		var got flexInt
+		if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+			t.Errorf("%s: %v", tt.json, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.json, got, tt.want)
+		}
+	}
+}
+
+// TestFlexIntCourse checks that an unknown seat count does not fail the
+// record, reads as unknown rather than full and survives a snapshot round trip
+func TestFlexIntCourse(t *testing.T) {
+	var c Course
+	if err := json.Unmarshal([]byte(`{"kch":"A1","syrs":"不限","pkrs":"","xf":"2"}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Syrs.known() || c.Pkrs.known() {
+		t.Errorf("counts should be unknown, got syrs %d pkrs %d", c.Syrs, c.Pkrs)
+	}
+	if !mayHaveSeats(c) {
+		t.Error("an unknown seat count should count as possibly available")
+	}
+	if c.Syrs.String() != "-" {
+		t.Errorf("unknown count shows as %q", c.Syrs.String())
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Course
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Syrs.known() {
+		t.Errorf("unknown count lost in round trip: %s", data)
+	}
+}
+
+// TestFlexFloatUnmarshal decodes credits, reading anything unparseable as 0
+func TestFlexFloatUnmarshal(t *testing.T) {
+	tests := []struct {
+		json string
+		want flexFloat
+	}{
+		{`"1.5"`, 1.5},
+		{`2`, 2},
+		{`""`, 0},
+		{`null`, 0},
+		{`"不限"`, 0},
+	}
+	for _, tt := range tests {
+		var got flexFloat
+		if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+			t.Errorf("%s: %v", tt.json, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.json, got, tt.want)
+		}
+	}
+}
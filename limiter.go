@@ -0,0 +1,171 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// Default pacing used when neither the profile nor flags set one
+const (
+	defaultRateLimit      = 5.0         // Total selection requests per second
+	defaultCourseInterval = time.Second // Minimum interval between requests of one course
+)
+
+// rateLimiter is a token bucket shared by all course workers. When tokens are
+// scarce they are shared by weighted fair queueing: every course gets a share
+// of the budget in proportion to its priority, so important courses get more
+// of it without starving the others.
+type rateLimiter struct {
+	mu       sync.Mutex
+	rate     float64 // Tokens added per second, 0 means unlimited
+	capacity float64 // Maximum number of stored tokens
+	tokens   float64
+	last     time.Time
+	waiters  waiterQueue
+	seq      int
+	virtual  float64            // Finish tag of the waiter served last
+	finish   map[string]float64 // Finish tag of the latest request of each course
+	wake     chan struct{}
+}
+
+// Global limiter for selection requests
+var limiter *rateLimiter
+
+// newRateLimiter creates a limiter allowing rate requests per second
+func newRateLimiter(rate float64) *rateLimiter {
+	capacity := rate
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	l := &rateLimiter{
+		rate:     rate,
+		capacity: capacity,
+		tokens:   1,
+		last:     time.Now(),
+		finish:   make(map[string]float64),
+		wake:     make(chan struct{}, 1),
+	}
+	if rate > 0 {
+		go l.run()
+	}
+	return l
+}
+
+// acquire blocks until the course may send one request and reports false if
+// ctx was cancelled first. A course of priority p is served p times as often
+// as one of priority 1 while both are waiting.
+func (l *rateLimiter) acquire(ctx context.Context, course string, priority int) bool {
+	if l.rate <= 0 {
+		return ctx.Err() == nil
+	}
+
+	w := &waiter{ready: make(chan struct{})}
+
+	l.mu.Lock()
+	// A course that was idle starts from the current virtual time rather
+	// than cashing in the turns it did not use
+	start := max(l.virtual, l.finish[course])
+	w.tag = start + 1/float64(max(priority, 1))
+	l.finish[course] = w.tag
+	l.seq++
+	w.seq = l.seq
+	heap.Push(&l.waiters, w)
+	l.mu.Unlock()
+
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+
+	select {
+	case <-w.ready:
+		return true
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.index >= 0 {
+		heap.Remove(&l.waiters, w.index)
+	} else {
+		// Served while giving up, the token goes back to the bucket
+		l.tokens = min(l.tokens+1, l.capacity)
+	}
+	return false
+}
+
+// run hands out tokens to waiters in finish tag order as they become available
+func (l *rateLimiter) run() {
+	for {
+		l.mu.Lock()
+		for len(l.waiters) == 0 {
+			l.mu.Unlock()
+			<-l.wake
+			l.mu.Lock()
+		}
+
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.capacity {
+			l.tokens = l.capacity
+		}
+		l.last = now
+
+		if l.tokens >= 1 {
+			l.tokens--
+			w := heap.Pop(&l.waiters).(*waiter)
+			l.virtual = max(l.virtual, w.tag)
+			l.mu.Unlock()
+			close(w.ready)
+			continue
+		}
+
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+		time.Sleep(wait)
+	}
+}
+
+// waiter is a worker blocked in acquire
+type waiter struct {
+	tag   float64 // Virtual finish time, lower is served first
+	seq   int     // Arrival order, breaks ties between equal tags
+	index int     // Position in the queue, -1 once served or removed
+	ready chan struct{}
+}
+
+// waiterQueue is a heap of waiters ordered by finish tag, then arrival
+type waiterQueue []*waiter
+
+func (q waiterQueue) Len() int { return len(q) }
+
+func (q waiterQueue) Less(i, j int) bool {
+	if q[i].tag != q[j].tag {
+		return q[i].tag < q[j].tag
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waiterQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waiterQueue) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waiterQueue) Pop() any {
+	old := *q
+	n := len(old)
+	w := old[n-1]
+	w.index = -1
+	*q = old[:n-1]
+	return w
+}
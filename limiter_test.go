@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestLimiterSharesByPriority keeps 15 courses waiting on a busy limiter and
+// checks that every course is served, higher priorities more often
+func TestLimiterSharesByPriority(t *testing.T) {
+	l := newRateLimiter(1000)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	const courses = 15
+	var counts [courses + 1]int
+	var wg sync.WaitGroup
+	for priority := 1; priority <= courses; priority++ {
+		wg.Add(1)
+		go func(priority int) {
+			defer wg.Done()
+			for l.acquire(ctx, strconv.Itoa(priority), priority) {
+				counts[priority]++
+			}
+		}(priority)
+	}
+	wg.Wait()
+
+	for priority := 1; priority <= courses; priority++ {
+		if counts[priority] == 0 {
+			t.Errorf("priority %d was never served: %v", priority, counts[1:])
+		}
+	}
+	if counts[courses] <= 2*counts[1] {
+		t.Errorf("priority %d should be served far more often than priority 1: %v", courses, counts[1:])
+	}
+}
+
+// TestLimiterAcquireCancelled checks that a cancelled waiter returns and
+// leaves the queue without taking a token
+func TestLimiterAcquireCancelled(t *testing.T) {
+	l := newRateLimiter(0.5)
+	if !l.acquire(context.Background(), "a", 1) {
+		t.Fatal("the first token should be available")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	if l.acquire(ctx, "b", 1) {
+		t.Fatal("acquire should fail once ctx is done")
+	}
+	if waited := time.Since(started); waited > time.Second {
+		t.Errorf("acquire blocked %v after ctx was done", waited)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.waiters) != 0 {
+		t.Errorf("%d waiters left in the queue", len(l.waiters))
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// errInterrupted is returned when the user presses Ctrl+C while editing a line
+var errInterrupted = errors.New("interrupted")
+
+// Maximum number of history entries kept in memory and on disk
+const historyLimit = 500
+
+// lineEditor reads lines from stdin. On a terminal it offers cursor movement,
+// history and tab completion; otherwise it falls back to plain line reading.
+type lineEditor struct {
+	in          *bufio.Reader
+	history     []string
+	historyFile string
+	complete    func(words []string) []string // Candidates for the last word, given all words
+}
+
+// ansiEnabled reports whether stdout understands ANSI escape sequences
+var ansiEnabled bool
+
+// Global console shared by every prompt, so buffered input is never lost
+// between different readers of stdin
+var console = newLineEditor()
+
+// newLineEditor creates an editor on stdin and loads the saved history
+func newLineEditor() *lineEditor {
+	ed := &lineEditor{in: bufio.NewReader(os.Stdin)}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		ed.historyFile = filepath.Join(home, ".qzjwxt_xk_history")
+		if data, err := os.ReadFile(ed.historyFile); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					ed.history = append(ed.history, line)
+				}
+			}
+			if len(ed.history) > historyLimit {
+				ed.history = ed.history[len(ed.history)-historyLimit:]
+			}
+		}
+	}
+	return ed
+}
+
+// readInput prompts for a single answer without recording it in the history
+func readInput(prompt string) string {
+	line, _ := console.edit(prompt, false)
+	return strings.TrimSpace(line)
+}
+
+// readCommand prompts for a command line with history and tab completion
+func (ed *lineEditor) readCommand(prompt string) (string, error) {
+	line, err := ed.edit(prompt, true)
+	line = strings.TrimSpace(line)
+	if err == nil && line != "" {
+		ed.addHistory(line)
+	}
+	return line, err
+}
+
+// addHistory records a command, skipping immediate repeats, and appends it to the history file
+func (ed *lineEditor) addHistory(line string) {
+	if n := len(ed.history); n > 0 && ed.history[n-1] == line {
+		return
+	}
+	ed.history = append(ed.history, line)
+	if len(ed.history) > historyLimit {
+		ed.history = ed.history[len(ed.history)-historyLimit:]
+	}
+
+	if ed.historyFile == "" {
+		return
+	}
+	if f, err := os.OpenFile(ed.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600); err == nil {
+		fmt.Fprintln(f, line)
+		f.Close()
+	}
+}
+
+// edit reads one line, using the interactive editor when stdin and stdout are terminals
+func (ed *lineEditor) edit(prompt string, interactive bool) (string, error) {
+	if !isTerminal(os.Stdin.Fd()) || !ansiEnabled {
+		fmt.Print(prompt)
+		line, err := ed.in.ReadString('\n')
+		if err != nil && line != "" {
+			err = nil
+		}
+		return strings.TrimRight(line, "\r\n"), err
+	}
+
+	restore, err := makeRaw(os.Stdin.Fd())
+	if err != nil {
+		fmt.Print(prompt)
+		line, err := ed.in.ReadString('\n')
+		return strings.TrimRight(line, "\r\n"), err
+	}
+	defer restore()
+
+	var buf []rune
+	pos := 0
+	historyIndex := len(ed.history)
+	var pending []rune // Line being edited before browsing the history
+	lastWasTab := false
+
+	redraw := func() {
+		fmt.Printf("\r%s%s\x1b[K", prompt, string(buf))
+		if back := displayWidth(string(buf[pos:])); back > 0 {
+			fmt.Printf("\x1b[%dD", back)
+		}
+	}
+	setLine := func(line []rune) {
+		buf = append([]rune(nil), line...)
+		pos = len(buf)
+	}
+
+	redraw()
+	for {
+		r, _, err := ed.in.ReadRune()
+		if err != nil {
+			fmt.Print("\r\n")
+			return string(buf), err
+		}
+
+		tab := false
+		switch r {
+		case '\r', '\n':
+			fmt.Print("\r\n")
+			return string(buf), nil
+		case 3: // Ctrl+C
+			fmt.Print("^C\r\n")
+			return "", errInterrupted
+		case 4: // Ctrl+D
+			if len(buf) == 0 {
+				fmt.Print("\r\n")
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+		case 1: // Ctrl+A
+			pos = 0
+		case 5: // Ctrl+E
+			pos = len(buf)
+		case 2: // Ctrl+B
+			if pos > 0 {
+				pos--
+			}
+		case 6: // Ctrl+F
+			if pos < len(buf) {
+				pos++
+			}
+		case 21: // Ctrl+U
+			buf = buf[pos:]
+			pos = 0
+		case 11: // Ctrl+K
+			buf = buf[:pos]
+		case 23: // Ctrl+W
+			start := pos
+			for start > 0 && buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && buf[start-1] != ' ' {
+				start--
+			}
+			buf = append(buf[:start], buf[pos:]...)
+			pos = start
+		case 12: // Ctrl+L
+			fmt.Print("\x1b[H\x1b[2J")
+		case '\t':
+			tab = true
+			if interactive && ed.complete != nil {
+				buf, pos = ed.completeLine(buf, pos, lastWasTab)
+			}
+		case 27: // Escape sequence
+			key := ed.readEscape()
+			switch key {
+			case "up":
+				if interactive && historyIndex > 0 {
+					if historyIndex == len(ed.history) {
+						pending = append([]rune(nil), buf...)
+					}
+					historyIndex--
+					setLine([]rune(ed.history[historyIndex]))
+				}
+			case "down":
+				if interactive && historyIndex < len(ed.history) {
+					historyIndex++
+					if historyIndex == len(ed.history) {
+						setLine(pending)
+					} else {
+						setLine([]rune(ed.history[historyIndex]))
+					}
+				}
+			case "right":
+				if pos < len(buf) {
+					pos++
+				}
+			case "left":
+				if pos > 0 {
+					pos--
+				}
+			case "home":
+				pos = 0
+			case "end":
+				pos = len(buf)
+			case "delete":
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+				}
+			}
+		default:
+			if r >= 32 {
+				buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+				pos++
+			}
+		}
+
+		lastWasTab = tab
+		redraw()
+	}
+}
+
+// readEscape decodes the rest of an ANSI escape sequence into a key name
+func (ed *lineEditor) readEscape() string {
+	next, _, err := ed.in.ReadRune()
+	if err != nil || (next != '[' && next != 'O') {
+		return ""
+	}
+
+	code, _, err := ed.in.ReadRune()
+	if err != nil {
+		return ""
+	}
+	switch code {
+	case 'A':
+		return "up"
+	case 'B':
+		return "down"
+	case 'C':
+		return "right"
+	case 'D':
+		return "left"
+	case 'H':
+		return "home"
+	case 'F':
+		return "end"
+	}
+
+	// Sequences such as "3~" (delete) or "1;5C" (modified arrows)
+	seq := string(code)
+	for code < 0x40 || code > 0x7e {
+		code, _, err = ed.in.ReadRune()
+		if err != nil {
+			return ""
+		}
+		seq += string(code)
+	}
+	switch seq {
+	case "3~":
+		return "delete"
+	case "1~", "7~":
+		return "home"
+	case "4~", "8~":
+		return "end"
+	}
+	return ""
+}
+
+// completeLine completes the word before the cursor. A unique candidate is
+// inserted with a trailing space, several candidates are completed to their
+// common prefix and listed when Tab is pressed twice.
+func (ed *lineEditor) completeLine(buf []rune, pos int, listAll bool) ([]rune, int) {
+	before := string(buf[:pos])
+	words := strings.Fields(before)
+	if before == "" || strings.HasSuffix(before, " ") {
+		words = append(words, "")
+	}
+	word := words[len(words)-1]
+
+	var matches []string
+	for _, candidate := range ed.complete(words) {
+		if strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(word)) {
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+
+	if len(matches) == 0 {
+		return buf, pos
+	}
+
+	replacement := matches[0] + " "
+	if len(matches) > 1 {
+		replacement = commonPrefix(matches)
+		if listAll {
+			fmt.Print("\r\n" + strings.Join(matches, "  ") + "\r\n")
+		}
+	}
+
+	start := pos - len([]rune(word))
+	newBuf := append([]rune(nil), buf[:start]...)
+	newBuf = append(newBuf, []rune(replacement)...)
+	newPos := len(newBuf)
+	newBuf = append(newBuf, buf[pos:]...)
+	return newBuf, newPos
+}
+
+// commonPrefix returns the longest common prefix of the strings
+func commonPrefix(list []string) string {
+	prefix := []rune(list[0])
+	for _, s := range list[1:] {
+		r := []rune(s)
+		n := 0
+		for n < len(prefix) && n < len(r) && prefix[n] == r[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
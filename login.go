@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// loginStrategy builds the login request of one variant of the QZ login form.
+// Everything after the login only needs the cookies, so schools differ here alone.
+type loginStrategy interface {
+	// form returns the path to post the credentials to and the form data. It
+	// may ask the server for a key first, sending the session cookies along,
+	// and returns the session cookies to send with the login.
+	form(e *egress, username string, password string, sessionCookies []*http.Cookie) (path string, data string, cookies []*http.Cookie, err error)
+}
+
+// Login strategies by the loginMethod of the profile
+var loginStrategies = map[string]loginStrategy{
+	"loginToXk": base64Login{},
+	"sess":      sessLogin{},
+}
+
+// currentLoginStrategy returns the login strategy selected by the profile
+func currentLoginStrategy() (loginStrategy, error) {
+	if profile.LoginMethod == "" {
+		return base64Login{}, nil
+	}
+	strategy, ok := loginStrategies[profile.LoginMethod]
+	if !ok {
+		return nil, fmt.Errorf("未知的登录方式 %q，可选 loginToXk 或 sess", profile.LoginMethod)
+	}
+	return strategy, nil
+}
+
+// base64Login posts base64 encoded credentials to xk/LoginToXk
+type base64Login struct{}
+
+func (base64Login) form(e *egress, username string, password string, sessionCookies []*http.Cookie) (string, string, []*http.Cookie, error) {
+	usernameBase64 := base64.StdEncoding.EncodeToString([]byte(username))
+	passwordBase64 := base64.StdEncoding.EncodeToString([]byte(password))
+
+	// Format exactly as in the example: MjAyMzEyMDA5Nzc4%25%25%25TGl1MDUwNDIw%3D
+	encoded := fmt.Sprintf("%s%%25%%25%%25%s%%3D", usernameBase64, passwordBase64)
+	return "/xk/LoginToXk", "encoded=" + encoded, sessionCookies, nil
+}
+
+// sessLogin is the Logon.do login of newer QZ versions. The server hands out
+// a one-time key "scode#sxh" that scrambles the credentials, bound to the
+// session that asked for it.
+type sessLogin struct{}
+
+func (sessLogin) form(e *egress, username string, password string, sessionCookies []*http.Cookie) (string, string, []*http.Cookie, error) {
+	req, err := http.NewRequest("POST", siteURL("/Logon.do?method=logon&flag=sess"), nil)
+	if err != nil {
+		return "", "", nil, err
+	}
+	req.Header.Set("Host", siteHost())
+	for _, cookie := range sessionCookies {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := e.loginClient.Do(req)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("获取登录密钥失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("读取登录密钥失败: %v", err)
+	}
+	if kind := classifyResponse(resp.StatusCode, body); kind.isThrottled() {
+		delay := backoffs.failure(backoffKey(e, req.URL.Host), resp.Header.Get("Retry-After"))
+		return "", "", nil, &throttledError{kind: kind, delay: delay}
+	}
+	if resp.StatusCode != 200 {
+		return "", "", nil, fmt.Errorf("获取登录密钥失败，状态码: %d", resp.StatusCode)
+	}
+
+	scode, sxh, ok := strings.Cut(strings.TrimSpace(string(body)), "#")
+	if !ok || scode == "" || sxh == "" {
+		preview := []rune(string(body))
+		if len(preview) > 50 {
+			preview = preview[:50]
+		}
+		return "", "", nil, fmt.Errorf("登录密钥格式错误: %s", string(preview))
+	}
+
+	encoded := scrambleCredentials(username+"%%%"+password, scode, sxh)
+	data := "userAccount=" + url.QueryEscape(username) + "&userPassword=&encoded=" + url.QueryEscape(encoded)
+	return "/Logon.do?method=logon", data, mergeCookieList(sessionCookies, resp.Cookies()), nil
+}
+
+// scrambleCredentials interleaves "user%%%pass" with the key as the login page
+// script does: each of the first 20 characters is followed by the next sxh[i]
+// characters of scode, and the rest of the credentials is appended unchanged.
+func scrambleCredentials(code string, scode string, sxh string) string {
+	chars := []rune(code)
+	var b strings.Builder
+	for i, c := range chars {
+		if i >= 20 {
+			b.WriteString(string(chars[i:]))
+			break
+		}
+		b.WriteRune(c)
+
+		n := 0
+		if i < len(sxh) && sxh[i] >= '0' && sxh[i] <= '9' {
+			n = int(sxh[i] - '0')
+		}
+		n = min(n, len(scode))
+		b.WriteString(scode[:n])
+		scode = scode[n:]
+	}
+	return b.String()
+}
+
+// mergeCookieList returns updates together with the cookies of base that
+// were not replaced by a cookie of the same name
+func mergeCookieList(base []*http.Cookie, updates []*http.Cookie) []*http.Cookie {
+	cookies := append([]*http.Cookie(nil), updates...)
+	for _, cookie := range base {
+		replaced := false
+		for _, c := range updates {
+			if c.Name == cookie.Name {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			cookies = append(cookies, cookie)
+		}
+	}
+	return cookies
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestScrambleCredentials compares the port with the output of the encoding
+// script of the Logon.do?flag=sess login page, run under Node on the same
+// account, password and "scode#sxh" key
+func TestScrambleCredentials(t *testing.T) {
+	tests := []struct {
+		account, password, key string
+		want                   string
+	}{
+		{
+			"2021001234", "Passw0rd!",
+			"a8Kd93LmQz0xP2vB7nT4yW6eR1uH5sJcF9gZ#31203121310221301213",
+			"2a8K0d29310LmQ0z10x2P32vB47%%nT%4yPWa6eRss1wuH05rsJcd!",
+		},
+		{
+			// Past 20 characters the rest is appended unchanged
+			"20210012345678", "mySecret#2024long",
+			"Xk9fG2hLp4Qr7sT0vW3yZ6bN8mC1dE5aJ#23110231021302113221",
+			"2Xk09fG221h00Lp14Qr2734sT506vW378yZ%6%b%N8mmC1ydES5ecret#2024long",
+		},
+		{
+			// An sxh shorter than the credentials inserts nothing after it ends
+			"ab", "c",
+			"QWERTYUIOP#1234",
+			"aQbWE%RTY%UIOP%c",
+		},
+	}
+	for _, tt := range tests {
+		scode, sxh, _ := strings.Cut(tt.key, "#")
+		if got := scrambleCredentials(tt.account+"%%%"+tt.password, scode, sxh); got != tt.want {
+			t.Errorf("%s/%s:\ngot  %s\nwant %s", tt.account, tt.password, got, tt.want)
+		}
+	}
+}
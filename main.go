@@ -1,40 +1,79 @@
 package main
 
 import (
-	"bufio"
-	"encoding/base64"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
-	"regexp"
 	"strings"
-	"sync"
-	"time"
 )
 
 // CourseSession represents a course selection session
 type CourseSession struct {
-	Term string // 学年学期
-	Name string // 选课名称
-	Time string // 选课时间
-	URL  string // 选课URL
+	Term     string // 学年学期
+	Name     string // 选课名称
+	Time     string // 选课时间
+	Status   string // 未开始, 进行中 or 已结束, "" if unknown
+	EntryURL string // 进入选课 link as found on the page
+	ViewURL  string // 查看 link as found on the page
+	URL      string // URL used to enter the session
 }
 
-// Course represents a course from the response
+// Course represents a course from the response. Numeric fields use tolerant
+// types because schools send them either as numbers or as strings.
 type Course struct {
 	Kch      string     `json:"kch"`      // 课程编号
 	Kcmc     string     `json:"kcmc"`     // 课程名称
-	Xf       int        `json:"xf"`       // 学分
+	Xf       flexFloat  `json:"xf"`       // 学分
 	Skls     string     `json:"skls"`     // 上课老师
 	Sksj     string     `json:"sksj"`     // 上课时间
 	Skdd     string     `json:"skdd"`     // 上课地点
 	Xqmc     string     `json:"xqmc"`     // 上课校区
-	Syrs     string     `json:"syrs"`     // 剩余量
+	Syrs     flexInt    `json:"syrs"`     // 剩余量
 	Jx0404id string     `json:"jx0404id"` // 选课ID
 	Szkcflmc string     `json:"szkcflmc"` // 通选课类别
 	KkapList []KkapInfo `json:"kkapList"` // 课程安排信息
+
+	ZcxqjcList []ZcxqjcInfo `json:"zcxqjcList"` // 周次星期节次列表
+
+	Pkrs         flexInt    `json:"pkrs"`          // 排课人数 (容量)
+	Xkrs         flexInt    `json:"xkrs"`          // 已选人数
+	Xxrs         flexInt    `json:"xxrs"`          // 限选人数
+	Kcxzmc       flexString `json:"kcxzmc"`        // 课程性质
+	Zxs          flexInt    `json:"zxs"`           // 总学时
+	Kkdw         flexString `json:"kkdw"`          // 开课单位编号
+	Dwmc         flexString `json:"dwmc"`          // 开课单位名称
+	Xnxq01id     flexString `json:"xnxq01id"`      // 学年学期
+	Ktmc         flexString `json:"ktmc"`          // 课堂名称
+	Sfkfxk       flexString `json:"sfkfxk"`        // 是否开放选课, "1" 表示开放
+	Sftk         flexString `json:"sftk"`          // 是否停开, "1" 表示已停开
+	Ctsm         flexString `json:"ctsm"`          // 冲突说明
+	Xbyq         flexString `json:"xbyq"`          // 性别要求代码
+	Xbyqmc       flexString `json:"xbyqmc"`        // 性别要求
+	Kcjj         flexString `json:"kcjj"`          // 课程简介
+	FjFilename   flexString `json:"fj_filename"`   // 附件文件名
+	JxdgFilename flexString `json:"jxdg_filename"` // 教学大纲文件名
+}
+
+// room returns the classroom of a course as the course table shows it: the
+// room of the first arrangement, or 上课地点 if that has none
+func (c Course) room() string {
+	if len(c.KkapList) > 0 && c.KkapList[0].Jsmc != "" {
+		return c.KkapList[0].Jsmc
+	}
+	return c.Skdd
+}
+
+// ZcxqjcInfo is one teaching period of a course: week, weekday and period
+type ZcxqjcInfo struct {
+	Zc string `json:"zc"` // 周次
+	Xq string `json:"xq"` // 星期
+	Jc string `json:"jc"` // 节次
 }
 
 // KkapInfo represents course arrangement information
@@ -44,6 +83,8 @@ type KkapInfo struct {
 	Xq       string   `json:"xq"`       // 星期
 	Skjcmc   string   `json:"skjcmc"`   // 上课节次
 	Jsmc     string   `json:"jsmc"`     // 教室名称
+	Kssj     string   `json:"kssj"`     // 开始时间
+	Jssj     string   `json:"jssj"`     // 结束时间
 	SkzcList []string `json:"skzcList"` // 上课周次列表
 }
 
@@ -100,15 +141,162 @@ func (r *APIResponse) GetSuccessMessage() string {
 }
 
 // Global variables
-var courseMap map[string]string
+var courseCatalog []Course        // Every section of the selected session
 var selectedSession CourseSession // Store the selected session globally
 var storedUsername string         // Store username for re-login
 var storedPassword string         // Store password for re-login
-var storedEncoded string          // Store encoded credentials for re-login
-var cookiesMutex sync.Mutex       // Mutex for protecting cookies
-var sharedCookies []*http.Cookie  // Shared cookies that can be updated by any goroutine
+var icsPath string                // Export the timetable of the selected courses to this .ics file after a run
 
 func main() {
+	profilePath := flag.String("profile", "", "配置文件路径 (JSON)")
+	termStart := flag.String("term-start", "", "第一教学周周一日期, 例如 2025-09-01")
+	flag.StringVar(&icsPath, "ics", "", "选课结束后将已选课程 (包括之前已选的) 导出为 iCalendar (.ics) 文件，每次覆盖")
+	proxy := flag.String("proxy", "", "代理地址, 例如 socks5://127.0.0.1:1080，默认读取 HTTP_PROXY/HTTPS_PROXY 环境变量")
+	proxyPool := flag.String("proxy-pool", "", "选课请求使用的代理列表，逗号分隔")
+	sourceAddr := flag.String("source-addr", "", "绑定的本地源 IP 地址")
+	rate := flag.Float64("rate", 0, "所有课程合计每秒最多请求数 (默认 5, 负数表示不限制)")
+	interval := flag.String("course-interval", "", "单门课程两次请求的最小间隔 (默认 1s)")
+	startAt := flag.String("start", "", "定时开始选课的服务器时间, 例如 \"2025-06-25 12:00:00\"")
+	burst := flag.String("burst", "", "定时开始后的爆发阶段时长, 例如 5s")
+	burstPar := flag.Int("burst-parallel", 0, "爆发阶段每门课程的并发请求数 (默认 3)")
+	burstMax := flag.Int("burst-max", 0, "爆发阶段全局最多并发请求数 (默认 20)")
+	maxCredits := flag.Float64("max-credits", 0, "本次最多选的学分")
+	maxCourses := flag.Int("max-courses", 0, "本次最多选的门数")
+	categoryLimits := flag.String("category-limit", "", "按通选课类别限制, 例如 \"人文科学=4:2,艺术=2\" (类别=学分[:门数])")
+	preferDays := flag.String("prefer-days", "", "规划选课时偏好的星期, 例如 1-3,5")
+	preferPeriods := flag.String("prefer-periods", "", "规划选课时偏好的节次, 例如 1-4,9-10")
+	sessionFilter := flag.String("session", "", "等待名称或学期包含该文字的选课会话发布后自动进入")
+	sessionPollFlag := flag.String("session-poll", "", "等待选课会话时的查询间隔 (默认 30s)")
+	courses := flag.String("courses", "", "进入会话后自动加入选课篮的课程号或选课ID，逗号分隔")
+	autoGo := flag.Bool("auto-go", false, "自动进入会话后立即开始选课")
+	refresh := flag.String("catalog-refresh", "", "选课期间后台刷新课程列表的间隔, 例如 10m (默认不刷新)")
+	watch := flag.String("watch", "", "刷新课程列表时提示新开放的匹配教学班, 逗号分隔, 每项为空格分隔的关键词")
+	captchaFile := flag.String("captcha-file", "", "验证码图片保存路径 (默认保存到临时目录)")
+	events := flag.String("events", "", "事件输出格式, ndjson 表示每行一个 JSON 事件输出到 stdout，其他输出改到 stderr")
+	noColor := flag.Bool("no-color", false, "表格不使用颜色 (也可设置 NO_COLOR 环境变量)")
+	flag.Parse()
+
+	if err := setupEvents(*events); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if *profilePath != "" {
+		p, err := loadProfile(*profilePath)
+		if err != nil {
+			fmt.Printf("读取配置文件失败: %v\n", err)
+			return
+		}
+		profile = p
+	}
+	if *termStart != "" {
+		profile.TermStart = *termStart
+	}
+	if *proxy != "" {
+		profile.Proxy = *proxy
+	}
+	if *proxyPool != "" {
+		profile.ProxyPool = strings.Split(*proxyPool, ",")
+	}
+	if *sourceAddr != "" {
+		profile.SourceAddr = *sourceAddr
+	}
+	if *rate != 0 {
+		profile.RateLimit = *rate
+	}
+	if *interval != "" {
+		profile.CourseInterval = *interval
+	}
+	if *startAt != "" {
+		profile.StartAt = *startAt
+	}
+	if *burst != "" {
+		profile.BurstDuration = *burst
+	}
+	if *burstPar > 0 {
+		profile.BurstParallel = *burstPar
+	}
+	if *burstMax > 0 {
+		profile.BurstMaxInFlight = *burstMax
+	}
+	if _, err := burstDuration(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if *maxCredits > 0 {
+		profile.MaxCredits = *maxCredits
+	}
+	if *maxCourses > 0 {
+		profile.MaxCourses = *maxCourses
+	}
+	if *preferDays != "" {
+		profile.PreferDays = *preferDays
+	}
+	if *preferPeriods != "" {
+		profile.PreferPeriods = *preferPeriods
+	}
+	if *captchaFile != "" {
+		profile.CaptchaFile = *captchaFile
+	}
+	if *sessionFilter != "" {
+		profile.SessionFilter = *sessionFilter
+	}
+	if *sessionPollFlag != "" {
+		profile.SessionPoll = *sessionPollFlag
+	}
+	if _, err := sessionPoll(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := checkBaseURL(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if _, err := currentLoginStrategy(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if *courses != "" {
+		profile.Courses = strings.Split(*courses, ",")
+	}
+	if *autoGo {
+		profile.AutoGo = true
+	}
+	if *refresh != "" {
+		profile.CatalogRefresh = *refresh
+	}
+	if _, err := catalogRefresh(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if *watch != "" {
+		profile.Watch = strings.Split(*watch, ",")
+	}
+	if *categoryLimits != "" {
+		limits, err := parseCategoryLimits(*categoryLimits)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		profile.CategoryLimits = limits
+	}
+	if _, err := courseInterval(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	start, err := scheduledStart()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	limiter = newRateLimiter(rateLimit())
+
+	if err := setupEgresses(); err != nil {
+		fmt.Printf("网络配置错误: %v\n", err)
+		return
+	}
+	ansiEnabled = enableANSI(os.Stdout.Fd())
+	setupColor(*noColor)
+
 	// Display disclaimer at startup
 	fmt.Println("==============================================================================")
 	fmt.Println("⚠️  警告：请勿使用该项目进行任何形式的商业盈利行为，包括但不限于收费服务、转售代码、嵌入付费软件等。")
@@ -125,108 +313,97 @@ func main() {
 	fmt.Println()
 
 	// Step 1: Get username and password from user input
-	reader := bufio.NewReader(os.Stdin)
-
-	fmt.Print("请输入账号: ")
-	username, _ := reader.ReadString('\n')
-	username = strings.TrimSpace(username)
-
-	fmt.Print("请输入密码: ")
-	password, _ := reader.ReadString('\n')
-	password = strings.TrimSpace(password)
+	username := readInput("请输入账号: ")
+	password := readInput("请输入密码: ")
 
 	// Store credentials for re-login if needed
 	storedUsername = username
 	storedPassword = password
 
-	// Encode username and password in base64
-	usernameBase64 := base64.StdEncoding.EncodeToString([]byte(username))
-	passwordBase64 := base64.StdEncoding.EncodeToString([]byte(password))
-
-	// Format exactly as in the example: MjAyMzEyMDA5Nzc4%25%25%25TGl1MDUwNDIw%3D
-	encoded := fmt.Sprintf("%s%%25%%25%%25%s%%3D", usernameBase64, passwordBase64)
-	storedEncoded = encoded
-
-	fmt.Println("编码后的登录参数:", encoded)
-
 	// Step 2: Login and get cookies
-	cookies, err := login(encoded)
+	cookies, err := login(primaryEgress, username, password)
+	emitEvent("login", map[string]any{"username": username, "ok": err == nil, "error": errorText(err)})
 	if err != nil {
 		fmt.Printf("登录失败: %v\n", err)
-		fmt.Println("按任意键退出...")
-		reader.ReadString('\n')
+		readInput("按回车键退出...")
 		return
 	}
 
 	fmt.Println("登录成功!")
+	primaryEgress.setCookies(cookies)
 
-	// Step 3: Request initial authentication and select course session
-	fmt.Println("\n开始选课会话认证...")
-	err = authenticate(cookies)
+	// Step 3: Everything else happens in the interactive shell
+	runShell(start)
+}
+
+// login sends a login request through the given egress and returns cookies.
+// The request is built by the login strategy of the profile. If the server
+// asks for a captcha, the user is prompted for it.
+func login(e *egress, username string, password string) ([]*http.Cookie, error) {
+	strategy, err := currentLoginStrategy()
 	if err != nil {
-		fmt.Printf("认证失败: %v\n", err)
-		return
+		return nil, err
 	}
 
-	// Step 4: Get course list
-	fmt.Println("\n获取课程列表...")
-	var getCourseErr error
-	courseMap, getCourseErr = getCourseList(cookies)
-	if getCourseErr != nil {
-		fmt.Printf("获取课程列表失败: %v\n", getCourseErr)
-		return
+	cookies, err := postLogin(e, strategy, username, password, nil, "")
+	for tries := 0; errors.Is(err, errCaptchaRequired) && tries < captchaAttempts; tries++ {
+		captchaCookies, code, captchaErr := solveCaptcha(e)
+		if captchaErr != nil {
+			return nil, captchaErr
+		}
+		cookies, err = postLogin(e, strategy, username, password, captchaCookies, code)
 	}
-
-	// Step 5: Let user select courses
-	selectedCourses := selectCourses(courseMap)
-
-	// Step 6: Register for selected courses
-	fmt.Println("\n开始选课，将在每次尝试前自动刷新认证会话...")
-	registerForCourses(selectedCourses, cookies)
+	return cookies, err
 }
 
-// login sends a login request and returns cookies
-func login(encoded string) ([]*http.Cookie, error) {
-	// Create POST request with encoded parameter
-	data := "encoded=" + encoded
-	fmt.Println("发送的完整请求体:", data)
+// postLogin sends one login request. A captcha code is sent together with
+// the cookies of the session that fetched the captcha image.
+func postLogin(e *egress, strategy loginStrategy, username string, password string, sessionCookies []*http.Cookie, captcha string) ([]*http.Cookie, error) {
+	// Create POST request with the form of the login strategy
+	path, data, sessionCookies, err := strategy.form(e, username, password, sessionCookies)
+	if err != nil {
+		return nil, err
+	}
+	if captcha != "" {
+		data = captchaField() + "=" + url.QueryEscape(captcha) + "&" + data
+	}
+	logf("发送的完整请求体: %v\n", data)
 
-	req, err := http.NewRequest("POST", "https://jw.educationgroup.cn/ytkjxy_jsxsd/xk/LoginToXk",
-		strings.NewReader(data))
+	req, err := http.NewRequest("POST", siteURL(path), strings.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Host", "jw.educationgroup.cn")
+	req.Header.Set("Host", siteHost())
 	req.Header.Set("Content-Length", fmt.Sprintf("%d", len(data)))
+	for _, cookie := range sessionCookies {
+		req.AddCookie(cookie)
+	}
 
 	// Print request details
-	fmt.Println("\n请求详情:")
-	fmt.Println("URL:", req.URL.String())
-	fmt.Println("Method:", req.Method)
-	fmt.Println("Headers:")
+	logf("\n请求详情:\n")
+	logf("URL: %v\n", req.URL.String())
+	logf("Method: %v\n", req.Method)
+	logf("Headers:\n")
 	for name, values := range req.Header {
 		for _, value := range values {
-			fmt.Printf("  %s: %s\n", name, value)
+			logf("  %s: %s\n", name, value)
 		}
 	}
 
-	// Disable automatic redirects to capture the 302 response
-	client := &http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-	}
+	// Respect the backoff of the host if it is throttling us
+	backoffs.wait(context.Background(), backoffKey(e, req.URL.Host))
 
-	resp, err := client.Do(req)
+	// The login client disables automatic redirects to capture the 302 response
+	resp, err := e.loginClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	// Print response status for debugging
-	fmt.Println("\n响应状态码:", resp.StatusCode)
+	logf("\n响应状态码: %v\n", resp.StatusCode)
 
 	// For successful login, status should be 302 (redirect)
 	if resp.StatusCode != 302 {
@@ -234,39 +411,47 @@ func login(encoded string) ([]*http.Cookie, error) {
 		body, _ := io.ReadAll(resp.Body)
 		bodyStr := string(body)
 
-		fmt.Println("\n登录失败! 响应体预览:")
+		logf("\n登录失败! 响应体预览:\n")
 		previewLen := 500
 		if len(body) < previewLen {
 			previewLen = len(body)
 		}
-		fmt.Printf("%s\n", body[:previewLen])
+		logf("%s\n", body[:previewLen])
 
-		// Try to extract more specific error messages
-		errorMsg := "登录失败"
-		if strings.Contains(bodyStr, "密码错误") || strings.Contains(bodyStr, "密码不正确") {
-			errorMsg = "密码错误"
-		} else if strings.Contains(bodyStr, "账号不存在") || strings.Contains(bodyStr, "用户名不存在") {
-			errorMsg = "账号不存在"
-		} else if strings.Contains(bodyStr, "验证码") && strings.Contains(bodyStr, "错误") {
-			errorMsg = "验证码错误"
+		// A WAF page or HTTP error is not a credential problem, back off instead
+		if kind := classifyResponse(resp.StatusCode, body); kind.isThrottled() {
+			delay := backoffs.failure(backoffKey(e, req.URL.Host), resp.Header.Get("Retry-After"))
+			return nil, &throttledError{kind: kind, delay: delay}
 		}
 
-		return nil, fmt.Errorf("%s", errorMsg)
+		// Try to extract more specific error messages
+		switch {
+		case strings.Contains(bodyStr, "密码错误") || strings.Contains(bodyStr, "密码不正确"):
+			return nil, errWrongPassword
+		case strings.Contains(bodyStr, "账号不存在") || strings.Contains(bodyStr, "用户名不存在"):
+			return nil, errNoAccount
+		case strings.Contains(bodyStr, "验证码") && strings.Contains(bodyStr, "错误"):
+			// A bare 验证码 also matches the label of the captcha field
+			return nil, errCaptchaRequired
+		}
+		return nil, fmt.Errorf("登录失败")
 	}
 
+	backoffs.success(backoffKey(e, req.URL.Host))
+
 	// Print all headers for debugging
-	fmt.Println("响应头:")
+	logf("响应头:\n")
 	for name, values := range resp.Header {
 		for _, value := range values {
-			fmt.Printf("%s: %s\n", name, value)
+			logf("%s: %s\n", name, value)
 		}
 	}
 
-	// Print all cookies
-	cookies := resp.Cookies()
-	fmt.Println("\n收到的Cookie:")
+	// Print all cookies, keeping the session cookies unless they were replaced
+	cookies := mergeCookieList(sessionCookies, resp.Cookies())
+	logf("\n收到的Cookie:\n")
 	for i, cookie := range cookies {
-		fmt.Printf("%d. %s = %s (Domain: %s, Path: %s)\n",
+		logf("%d. %s = %s (Domain: %s, Path: %s)\n",
 			i+1, cookie.Name, cookie.Value, cookie.Domain, cookie.Path)
 	}
 
@@ -278,88 +463,32 @@ func login(encoded string) ([]*http.Cookie, error) {
 	// Check for the redirect location
 	location := resp.Header.Get("Location")
 	if location != "" {
-		fmt.Println("\n重定向地址:", location)
+		logf("\n重定向地址: %v\n", location)
 	}
 
 	return cookies, nil
 }
 
-// authenticate sends an authentication request
-func authenticate(cookies []*http.Cookie) error {
-	// First, get the list of available course selection sessions
-	sessions, err := getSessionList(cookies)
-	if err != nil {
-		return fmt.Errorf("failed to get session list: %v", err)
-	}
-
-	// Display available sessions to the user
-	fmt.Println("\n可用的选课会话:")
-	fmt.Printf("%-4s %-15s %-20s %-25s\n", "序号", "学年学期", "选课名称", "选课时间")
-	fmt.Println(strings.Repeat("-", 70))
-
-	for i, session := range sessions {
-		fmt.Printf("%-4d %-15s %-20s %-25s\n", i+1, session.Term, session.Name, session.Time)
-	}
-
-	// Let user select a session
-	reader := bufio.NewReader(os.Stdin)
-	var localSelectedSession CourseSession
-
-	// Always require manual selection, even if there's only one option
-	for {
-		fmt.Print("\n请选择选课会话编号: ")
-		input, _ := reader.ReadString('\n')
-		input = strings.TrimSpace(input)
-
-		// Convert input to integer
-		var sessionIndex int
-		_, err := fmt.Sscanf(input, "%d", &sessionIndex)
-		if err != nil || sessionIndex < 1 || sessionIndex > len(sessions) {
-			fmt.Printf("无效的选择，请输入 1-%d 之间的数字\n", len(sessions))
-			continue
-		}
-
-		localSelectedSession = sessions[sessionIndex-1]
-		break
-	}
-
-	// Store the selected session in the global variable
-	selectedSession = localSelectedSession
-
-	fmt.Printf("\n已选择: %s - %s\n", selectedSession.Term, selectedSession.Name)
-	fmt.Printf("使用URL: %s\n", selectedSession.URL)
-
-	// Send authentication request with the selected session URL
-	err = refreshAuthentication(cookies)
-	if err != nil {
-		return err
-	}
-
-	fmt.Println("认证成功!")
-	return nil
-}
-
 // refreshAuthentication re-authenticates with the selected session URL
-func refreshAuthentication(cookies []*http.Cookie) error {
+func refreshAuthentication(e *egress, cookies []*http.Cookie) error {
 	if selectedSession.URL == "" {
 		return fmt.Errorf("没有选择选课会话")
 	}
 
-	authURL := "https://jw.educationgroup.cn" + selectedSession.URL
+	authURL := siteOrigin() + selectedSession.URL
 	req, err := http.NewRequest("GET", authURL, nil)
 	if err != nil {
 		return err
 	}
 
-	req.Header.Set("Host", "jw.educationgroup.cn")
+	req.Header.Set("Host", siteHost())
 
 	// Add cookies to request
 	for _, cookie := range cookies {
 		req.AddCookie(cookie)
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := e.client.Do(req)
 	if err != nil {
 		return err
 	}
@@ -372,7 +501,7 @@ func refreshAuthentication(cookies []*http.Cookie) error {
 			if len(body) < previewLen {
 				previewLen = len(body)
 			}
-			fmt.Printf("认证响应预览: %s\n", body[:previewLen])
+			logf("认证响应预览: %s\n", body[:previewLen])
 		}
 		return fmt.Errorf("认证失败，状态码: %d", resp.StatusCode)
 	}
@@ -388,45 +517,50 @@ func refreshAuthentication(cookies []*http.Cookie) error {
 	return nil
 }
 
-// getCourseList fetches the list of available courses
-func getCourseList(cookies []*http.Cookie) (map[string]string, error) {
+// loadCourseList fetches the courses of the selected session and stores them in courseCatalog
+func loadCourseList(cookies []*http.Cookie) ([]Course, error) {
 	data := "sEcho=1&iColumns=13&sColumns=&iDisplayStart=0&iDisplayLength=9999&mDataProp_0=kch&mDataProp_1=kcmc&mDataProp_2=xf&mDataProp_3=skls&mDataProp_4=sksj&mDataProp_5=skdd&mDataProp_6=xqmc&mDataProp_7=xxrs&mDataProp_8=xkrs&mDataProp_9=syrs&mDataProp_10=ctsm&mDataProp_11=szkcflmc&mDataProp_12=czOper"
 
 	req, err := http.NewRequest("POST",
-		"https://jw.educationgroup.cn/ytkjxy_jsxsd/xsxkkc/xsxkGgxxkxk?kcxx=&skls=&skxq=&skjc=&sfym=false&sfct=false&szjylb=&sfxx=true",
+		siteURL("/xsxkkc/xsxkGgxxkxk?kcxx=&skls=&skxq=&skjc=&sfym=false&sfct=false&szjylb=&sfxx=true"),
 		strings.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=UTF-8")
-	req.Header.Set("Host", "jw.educationgroup.cn")
+	req.Header.Set("Host", siteHost())
 
 	// Add cookies to request
 	for _, cookie := range cookies {
 		req.AddCookie(cookie)
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := primaryEgress.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("failed to get course list with status code: %d", resp.StatusCode)
-	}
-
 	// Read and parse the response
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
 
-	// Check if response is HTML instead of JSON
-	if strings.Contains(string(body), "<html") {
-		return nil, fmt.Errorf("received HTML response instead of JSON, session might have expired or authentication failed")
+	// Check if response is a WAF page, an HTTP error or HTML instead of JSON
+	switch kind := classifyResponse(resp.StatusCode, body); {
+	case kind == respSessionExpired:
+		return nil, errSessionExpired
+	case kind.isThrottled():
+		delay := backoffs.failure(backoffKey(primaryEgress, req.URL.Host), resp.Header.Get("Retry-After"))
+		return nil, &throttledError{kind: kind, delay: delay}
+	case kind == respUnexpected:
+		return nil, fmt.Errorf("课程列表返回了未知页面，状态码: %d", resp.StatusCode)
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get course list with status code: %d", resp.StatusCode)
 	}
 
 	var courseResp CourseResponse
@@ -435,18 +569,43 @@ func getCourseList(cookies []*http.Cookie) (map[string]string, error) {
 		return nil, err
 	}
 
-	// Create a map of kch -> jx0404id
-	courseMap := make(map[string]string)
+	courseCatalog = courseResp.AaData
+	return courseCatalog, nil
+}
 
-	// Print table header
-	fmt.Println("\n可选课程列表:")
-	fmt.Printf("%-10s %-20s %-4s %-10s %-20s %-20s %-8s %-6s %-20s\n",
-		"课程编号", "课程名称", "学分", "教师", "上课时间", "上课地点", "上课校区", "剩余量", "通选课类别")
-	fmt.Println(strings.Repeat("-", 120))
+// findCourses looks up an identifier in the catalog. A jx0404id matches one
+// section, a course code matches every section of that course.
+func findCourses(id string) []Course {
+	for _, c := range courseCatalog {
+		if c.Jx0404id == id {
+			return []Course{c}
+		}
+	}
 
-	for _, course := range courseResp.AaData {
-		courseMap[course.Kch] = course.Jx0404id
+	var matches []Course
+	for _, c := range courseCatalog {
+		if c.Kch == id {
+			matches = append(matches, c)
+		}
+	}
+	return matches
+}
 
+// printCourseTable prints courses in a table fitted to the terminal width
+func printCourseTable(courses []Course) {
+	tbl := newTable(
+		tableColumn{title: "课程编号", min: 8},
+		tableColumn{title: "课程名称", min: 8, max: 24, wrap: true},
+		tableColumn{title: "学分", min: 4},
+		tableColumn{title: "教师", min: 6, max: 12},
+		tableColumn{title: "上课时间", min: 10, max: 24, wrap: true},
+		tableColumn{title: "上课地点", min: 8, max: 20, wrap: true},
+		tableColumn{title: "上课校区", min: 4, max: 10},
+		tableColumn{title: "剩余量", min: 6, color: seatsColor},
+		tableColumn{title: "通选课类别", min: 6, max: 16},
+	)
+
+	for _, course := range courses {
 		// Get teacher name
 		teacherName := course.Skls
 		if len(course.KkapList) > 0 && course.KkapList[0].Jgxm != "" {
@@ -454,633 +613,59 @@ func getCourseList(cookies []*http.Cookie) (map[string]string, error) {
 		}
 
 		// Get classroom
-		classroom := course.Skdd
-		if len(course.KkapList) > 0 && course.KkapList[0].Jsmc != "" {
-			classroom = course.KkapList[0].Jsmc
-		}
+		classroom := course.room()
 
 		// Format course time
 		courseTime := course.Sksj
 		if courseTime == "" && len(course.KkapList) > 0 {
-			xq := ""
-			switch course.KkapList[0].Xq {
-			case "1":
-				xq = "星期一"
-			case "2":
-				xq = "星期二"
-			case "3":
-				xq = "星期三"
-			case "4":
-				xq = "星期四"
-			case "5":
-				xq = "星期五"
-			case "6":
-				xq = "星期六"
-			case "7":
-				xq = "星期日"
-			}
-			courseTime = fmt.Sprintf("%s %s %s", course.KkapList[0].Kkzc, xq, course.KkapList[0].Skjcmc)
+			courseTime = fmt.Sprintf("%s %s %s", course.KkapList[0].Kkzc, weekdayName(course.KkapList[0].Xq), course.KkapList[0].Skjcmc)
 		}
 
 		// Format remaining spots
-		remainingSpots := course.Syrs
-		if remainingSpots == "0" {
+		remainingSpots := course.Syrs.String()
+		if course.Syrs == 0 {
 			remainingSpots = "满"
 		}
 
-		// Print course info in a formatted way
-		fmt.Printf("%-10s %-20.20s %-4d %-10.10s %-20.20s %-20.20s %-8.8s %-6s %-20.20s\n",
-			course.Kch, course.Kcmc, course.Xf, teacherName, courseTime, classroom, course.Xqmc, remainingSpots, course.Szkcflmc)
-	}
-
-	return courseMap, nil
-}
-
-// selectCourses lets the user select courses to register for
-func selectCourses(courseMap map[string]string) []string {
-	reader := bufio.NewReader(os.Stdin)
-
-	// Use a map to track unique course selections
-	selectedCoursesMap := make(map[string]struct{})
-
-	fmt.Println("\n请输入要选择的课程号，每行一个，输入 'done' 结束:")
-
-	for {
-		fmt.Print("> ")
-		input, _ := reader.ReadString('\n')
-		input = strings.TrimSpace(input)
-
-		if input == "done" {
-			break
-		}
-
-		if _, exists := courseMap[input]; exists {
-			if _, alreadySelected := selectedCoursesMap[input]; alreadySelected {
-				fmt.Printf("课程 %s 已经添加过了，请勿重复添加\n", input)
-			} else {
-				selectedCoursesMap[input] = struct{}{}
-				fmt.Printf("已添加课程: %s\n", input)
-			}
-		} else {
-			fmt.Printf("课程号 %s 不存在，请重新输入\n", input)
-		}
-	}
-
-	// Convert map keys to slice
-	var selectedCourses []string
-	for kch := range selectedCoursesMap {
-		selectedCourses = append(selectedCourses, kch)
+		tbl.addRow(course.Kch, course.Kcmc, course.Xf.String(), teacherName, courseTime, classroom, course.Xqmc, remainingSpots, course.Szkcflmc)
 	}
-
-	fmt.Printf("\n已选择 %d 门课程\n", len(selectedCourses))
-	return selectedCourses
+	tbl.render()
 }
 
-// registerForCourses registers for the selected courses
-func registerForCourses(selectedCourses []string, cookies []*http.Cookie) {
-	var wg sync.WaitGroup
-	successChan := make(chan string)
-	doneChan := make(chan bool)
-
-	// Initialize shared cookies
-	cookiesMutex.Lock()
-	sharedCookies = cookies
-	cookiesMutex.Unlock()
-
-	// Start a goroutine to collect successful registrations
-	go func() {
-		successfulCourses := []string{}
-		for {
-			select {
-			case course := <-successChan:
-				successfulCourses = append(successfulCourses, course)
-				fmt.Printf("课程 %s 选课成功!\n", course)
-			case <-doneChan:
-				fmt.Println("\n选课结果汇总:")
-				if len(successfulCourses) > 0 {
-					fmt.Println("成功选上的课程:")
-					for _, course := range successfulCourses {
-						fmt.Printf("- %s\n", course)
-					}
-				} else {
-					fmt.Println("没有成功选上任何课程")
-				}
-				return
-			}
-		}
-	}()
-
-	// Start a goroutine for each course
-	for _, kch := range selectedCourses {
-		wg.Add(1)
-		go func(kch string) {
-			defer wg.Done()
-
-			jx0404id, exists := courseMap[kch]
-			if !exists {
-				fmt.Printf("课程 %s 在课程映射中不存在\n", kch)
-				return
-			}
-
-			attempts := 0
-			sessionExpiredCount := 0
-			lastReauthTime := time.Now().Add(-10 * time.Minute) // Initialize to past time
-
-			// Continue indefinitely until successful or manually stopped
-			for {
-				attempts++
-
-				// Get the latest cookies
-				cookiesMutex.Lock()
-				localCookies := make([]*http.Cookie, len(sharedCookies))
-				copy(localCookies, sharedCookies)
-				cookiesMutex.Unlock()
-
-				url := fmt.Sprintf("https://jw.educationgroup.cn/ytkjxy_jsxsd/xsxkkc/ggxxkxkOper?cfbs=null&jx0404id=%s&xkzy=&trjf=&_=%d",
-					jx0404id, time.Now().UnixMilli())
-
-				req, err := http.NewRequest("GET", url, nil)
-				if err != nil {
-					fmt.Printf("课程 %s 请求创建失败: %v\n", kch, err)
-					time.Sleep(1 * time.Second)
-					continue
-				}
-
-				req.Header.Set("Host", "jw.educationgroup.cn")
-
-				// Add cookies to request
-				for _, cookie := range localCookies {
-					req.AddCookie(cookie)
-				}
-
-				client := &http.Client{}
-				resp, err := client.Do(req)
-				if err != nil {
-					fmt.Printf("课程 %s 请求发送失败: %v\n", kch, err)
-					time.Sleep(1 * time.Second)
-					continue
-				}
-
-				body, err := io.ReadAll(resp.Body)
-				resp.Body.Close()
-				if err != nil {
-					fmt.Printf("课程 %s 响应读取失败: %v\n", kch, err)
-					time.Sleep(1 * time.Second)
-					continue
-				}
-
-				// Check if response is HTML instead of JSON (session expired)
-				responseStr := string(body)
-				sessionExpired := false
-
-				if strings.Contains(responseStr, "<html") {
-					sessionExpired = true
-				} else if strings.Contains(responseStr, "请重新登录") ||
-					strings.Contains(responseStr, "已在别处登录") ||
-					strings.Contains(responseStr, "登录超时") {
-					sessionExpired = true
-				}
-
-				if sessionExpired {
-					sessionExpiredCount++
-
-					// Check if another goroutine has recently re-authenticated (within 5 seconds)
-					timeSinceLastReauth := time.Since(lastReauthTime)
-					if timeSinceLastReauth < 5*time.Second {
-						fmt.Printf("课程 %s 检测到会话过期，但另一个进程刚刚重新认证，等待使用新令牌...\n", kch)
-						time.Sleep(1 * time.Second)
-						continue
-					}
-
-					fmt.Printf("课程 %s 会话已过期，准备重新登录...\n", kch)
-
-					// Re-login and refresh authentication
-					newCookies, loginErr := relogin()
-					if loginErr != nil {
-						fmt.Printf("课程 %s 重新登录失败: %v\n", kch, loginErr)
-						time.Sleep(3 * time.Second)
-						continue
-					}
-
-					// Update shared cookies for all goroutines
-					cookiesMutex.Lock()
-					sharedCookies = newCookies
-					lastReauthTime = time.Now()
-					cookiesMutex.Unlock()
-
-					fmt.Printf("课程 %s 已获取新的会话令牌，继续选课...\n", kch)
-					continue
-				}
-
-				fmt.Printf("课程 %s 响应: %s\n", kch, responseStr)
-
-				// Parse the response
-				var result APIResponse
-
-				err = json.Unmarshal(body, &result)
-				if err != nil {
-					fmt.Printf("课程 %s 响应解析失败: %v\n", kch, err)
-
-					// Check if the error might be due to session expiration
-					if strings.Contains(responseStr, "请重新登录") ||
-						strings.Contains(responseStr, "已在别处登录") ||
-						strings.Contains(responseStr, "登录超时") ||
-						strings.Contains(responseStr, "<html") {
-
-						fmt.Printf("课程 %s 检测到可能的会话过期，尝试重新认证...\n", kch)
-
-						// Check if another goroutine has recently re-authenticated
-						timeSinceLastReauth := time.Since(lastReauthTime)
-						if timeSinceLastReauth < 5*time.Second {
-							fmt.Printf("课程 %s 另一个进程刚刚重新认证，等待使用新令牌...\n", kch)
-							time.Sleep(1 * time.Second)
-							continue
-						}
-
-						// Re-login and refresh authentication
-						newCookies, loginErr := relogin()
-						if loginErr != nil {
-							fmt.Printf("课程 %s 重新登录失败: %v\n", kch, loginErr)
-							time.Sleep(3 * time.Second)
-							continue
-						}
-
-						// Update shared cookies for all goroutines
-						cookiesMutex.Lock()
-						sharedCookies = newCookies
-						lastReauthTime = time.Now()
-						cookiesMutex.Unlock()
-
-						fmt.Printf("课程 %s 已获取新的会话令牌，继续选课...\n", kch)
-					}
-
-					time.Sleep(1 * time.Second)
-					continue
-				}
-
-				// Check for success - handle different success message variations
-				successMsg := result.GetSuccessMessage()
-				if result.IsSuccess() && (strings.Contains(successMsg, "选课成功") ||
-					strings.Contains(successMsg, "success") ||
-					strings.Contains(successMsg, "成功")) {
-					successChan <- kch
-					return
-				}
-
-				fmt.Printf("课程 %s 尝试 %d: %s\n", kch, attempts, result.GetSuccessMessage())
-				time.Sleep(1 * time.Second)
-			}
-		}(kch)
-	}
-
-	// Wait for all goroutines to finish
-	wg.Wait()
-	doneChan <- true
-}
-
-// relogin performs the login process again and refreshes authentication
-func relogin() ([]*http.Cookie, error) {
-	fmt.Println("会话已过期，开始重新登录...")
+// relogin performs the login process again through an egress and refreshes authentication
+func relogin(e *egress) ([]*http.Cookie, error) {
+	logf("会话已过期，开始通过 %s 重新登录...\n", e.name)
 
 	// Use stored credentials
-	if storedEncoded == "" {
+	username, password := logins.credentials()
+	if username == "" {
 		return nil, fmt.Errorf("没有存储的登录凭据")
 	}
 
-	fmt.Println("使用已存储的登录凭据...")
+	logf("使用已存储的登录凭据...\n")
 
 	// Login and get new cookies
-	fmt.Println("正在重新获取登录令牌...")
-	cookies, err := login(storedEncoded)
+	logf("正在重新获取登录令牌...\n")
+	cookies, err := login(e, username, password)
 	if err != nil {
-		return nil, fmt.Errorf("重新登录失败: %v", err)
+		return nil, fmt.Errorf("重新登录失败: %w", err)
 	}
 
-	fmt.Println("重新登录成功，正在刷新选课会话认证...")
+	// Before a session is entered, for example while waiting for one to
+	// open, the login alone is the whole session
+	if selectedSession.URL == "" {
+		logf("重新登录成功\n")
+		return cookies, nil
+	}
+
+	logf("重新登录成功，正在刷新选课会话认证...\n")
 
 	// Refresh authentication with the selected session
-	err = refreshAuthentication(cookies)
+	err = refreshAuthentication(e, cookies)
 	if err != nil {
 		return nil, fmt.Errorf("重新认证失败: %v", err)
 	}
 
-	fmt.Println("会话认证刷新成功!")
+	logf("会话认证刷新成功!\n")
 	return cookies, nil
 }
-
-// getSessionList fetches the list of available course selection sessions
-func getSessionList(cookies []*http.Cookie) ([]CourseSession, error) {
-	req, err := http.NewRequest("GET", "https://jw.educationgroup.cn/ytkjxy_jsxsd/xsxk/xklc_list", nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Host", "jw.educationgroup.cn")
-
-	// Add cookies to request
-	for _, cookie := range cookies {
-		req.AddCookie(cookie)
-	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("failed to get session list with status code: %d", resp.StatusCode)
-	}
-
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	html := string(body)
-
-	// Extract all table rows with a more specific pattern for the table format
-	var sessions []CourseSession
-	sessionMap := make(map[string]CourseSession) // Use a map to avoid duplicates
-
-	// Step 1: Try to find the specific table by ID or class
-	tablePattern := regexp.MustCompile(`<table[^>]*(?:id=["']?tbKxkc["']?|class=["']?Nsb_r_list Nsb_table["']?)[^>]*>(?s:.*?)</table>`)
-	tableMatch := tablePattern.FindString(html)
-
-	if tableMatch != "" {
-		fmt.Println("找到选课表格")
-
-		// Step 2: Extract all rows from the table
-		rowPattern := regexp.MustCompile(`<tr>[\s\S]*?</tr>`)
-		allRows := rowPattern.FindAllString(tableMatch, -1)
-
-		// Filter out header rows
-		var dataRows []string
-		for _, row := range allRows {
-			// Skip rows that contain header cells or have the specific style attribute
-			if !strings.Contains(row, "<th") && !strings.Contains(row, "background-color:#D1E4F8") {
-				dataRows = append(dataRows, row)
-			}
-		}
-
-		if len(dataRows) > 0 {
-			fmt.Printf("找到 %d 行选课会话信息\n", len(dataRows))
-
-			for _, rowHTML := range dataRows {
-				// Remove HTML comments to avoid confusion
-				rowWithoutComments := removeHTMLComments(rowHTML)
-
-				// Extract the text from each cell
-				cellPattern := regexp.MustCompile(`<td[^>]*>([\s\S]*?)</td>`)
-				cellMatches := cellPattern.FindAllStringSubmatch(rowWithoutComments, -1)
-
-				if len(cellMatches) >= 3 {
-					// First three cells should contain term, name, and time
-					term := strings.TrimSpace(cellMatches[0][1])
-					name := strings.TrimSpace(cellMatches[1][1])
-
-					// Try to extract time from the third cell
-					timeStr := ""
-					if len(cellMatches) >= 3 {
-						timeStr = strings.TrimSpace(cellMatches[2][1])
-					}
-
-					// If time is empty, try to find it in any cell by looking for time patterns
-					if timeStr == "" {
-						timePattern := regexp.MustCompile(`\d{4}-\d{2}-\d{2}.*?~.*?\d{4}-\d{2}-\d{2}`)
-						for _, cell := range cellMatches {
-							if timeMatch := timePattern.FindString(cell[1]); timeMatch != "" {
-								timeStr = timeMatch
-								break
-							}
-						}
-					}
-
-					// If we still don't have time, look for the cell containing a date pattern
-					if timeStr == "" {
-						datePattern := regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
-						for _, cell := range cellMatches {
-							if dateMatch := datePattern.FindString(cell[1]); dateMatch != "" {
-								timeStr = strings.TrimSpace(cell[1])
-								break
-							}
-						}
-					}
-
-					// Extract the last cell for operation links
-					operationCell := cellMatches[len(cellMatches)-1][1]
-
-					// Extract links from the operation cell
-					linkPattern := regexp.MustCompile(`<a[^>]*href=["']([^"']*)["'][^>]*>([\s\S]*?)</a>`)
-					linkMatches := linkPattern.FindAllStringSubmatch(operationCell, -1)
-
-					for _, linkMatch := range linkMatches {
-						href := linkMatch[1]
-						linkText := strings.TrimSpace(linkMatch[2])
-
-						// Clean HTML tags from extracted text
-						cleanText := func(s string) string {
-							// Remove HTML tags
-							noTags := regexp.MustCompile(`<[^>]*>`).ReplaceAllString(s, "")
-							return strings.TrimSpace(noTags)
-						}
-
-						term = cleanText(term)
-						name = cleanText(name)
-						timeStr = cleanText(timeStr)
-
-						fmt.Printf("从表格提取: 学期=%s, 名称=%s, 时间=%s, 操作=%s\n",
-							term, name, timeStr, linkText)
-
-						// Convert xklc_view URLs to yxxsxk_index URLs if needed
-						sessionURL := href
-
-						// Extract all parameters from the URL without assuming specific names
-						if strings.Contains(sessionURL, "xklc_view") {
-							// Split the URL to get the path and parameters
-							urlParts := strings.SplitN(sessionURL, "?", 2)
-							if len(urlParts) == 2 {
-								basePath := strings.Replace(urlParts[0], "xklc_view", "yxxsxk_index", 1)
-								sessionURL = basePath + "?" + urlParts[1]
-								fmt.Printf("转换URL: %s => %s\n", href, sessionURL)
-							}
-						} else if strings.Contains(sessionURL, "xsxk_index") {
-							// Also convert any xsxk_index URLs to yxxsxk_index
-							urlParts := strings.SplitN(sessionURL, "?", 2)
-							if len(urlParts) == 2 {
-								basePath := strings.Replace(urlParts[0], "xsxk_index", "yxxsxk_index", 1)
-								sessionURL = basePath + "?" + urlParts[1]
-								fmt.Printf("转换URL: %s => %s\n", href, sessionURL)
-							}
-						}
-
-						// Use the full URL as the key for deduplication (don't rely on specific parameters)
-						sessionKey := sessionURL
-
-						sessionMap[sessionKey] = CourseSession{
-							Term: term,
-							Name: name,
-							Time: timeStr,
-							URL:  sessionURL,
-						}
-					}
-				}
-			}
-		}
-	}
-
-	// If we couldn't extract from the table, try other approaches
-	if len(sessionMap) == 0 {
-		fmt.Println("未从表格中提取到选课会话，尝试通用提取方法...")
-
-		// Look for any a tags with href containing xklc_view or xsxk_index
-		linkPattern := regexp.MustCompile(`<a[^>]*href=["']([^"']*)["'][^>]*>([\s\S]*?)</a>`)
-		linkMatches := linkPattern.FindAllStringSubmatch(html, -1)
-
-		var selectionLinks [][]string
-		for _, match := range linkMatches {
-			href := match[1]
-			linkText := match[2]
-
-			if (strings.Contains(href, "xsxk") || strings.Contains(href, "xklc")) &&
-				(strings.Contains(linkText, "选课") || strings.Contains(linkText, "进入")) {
-				selectionLinks = append(selectionLinks, match)
-			}
-		}
-
-		fmt.Printf("找到 %d 个可能的选课链接\n", len(selectionLinks))
-
-		for _, match := range selectionLinks {
-			href := match[1]
-			linkText := strings.TrimSpace(match[2])
-
-			// Try to find the containing table row to extract metadata
-			// Create a regex pattern that will match a <tr> containing this href
-			escapedHref := regexp.QuoteMeta(href)
-			rowPattern := regexp.MustCompile(`<tr>[\s\S]*?` + escapedHref + `[\s\S]*?</tr>`)
-			rowMatch := rowPattern.FindString(html)
-
-			// Default values
-			term := "当前学期"
-			name := linkText
-			timeStr := "当前时间"
-
-			if rowMatch != "" {
-				// Remove HTML comments to avoid confusion
-				rowMatch = removeHTMLComments(rowMatch)
-
-				// Extract cells from the row
-				cellPattern := regexp.MustCompile(`<td[^>]*>([\s\S]*?)</td>`)
-				cellMatches := cellPattern.FindAllStringSubmatch(rowMatch, -1)
-
-				if len(cellMatches) >= 3 {
-					// Try to extract text content without HTML tags
-					extractText := func(html string) string {
-						// Remove HTML tags
-						noTags := regexp.MustCompile(`<[^>]*>`).ReplaceAllString(html, "")
-						// Trim whitespace
-						return strings.TrimSpace(noTags)
-					}
-
-					// First three cells should contain term, name, and time
-					term = extractText(cellMatches[0][1])
-					name = extractText(cellMatches[1][1])
-
-					// Try to extract time from the third cell
-					if len(cellMatches) >= 3 {
-						timeStr = extractText(cellMatches[2][1])
-					}
-
-					// If time is empty, try to find it in any cell by looking for time patterns
-					if timeStr == "" || timeStr == "当前时间" {
-						timePattern := regexp.MustCompile(`\d{4}-\d{2}-\d{2}.*?~.*?\d{4}-\d{2}-\d{2}`)
-						for _, cell := range cellMatches {
-							if timeMatch := timePattern.FindString(cell[1]); timeMatch != "" {
-								timeStr = extractText(timeMatch)
-								break
-							}
-						}
-					}
-
-					// If we still don't have time, look for the cell containing a date pattern
-					if timeStr == "" || timeStr == "当前时间" {
-						datePattern := regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
-						for _, cell := range cellMatches {
-							if dateMatch := datePattern.FindString(cell[1]); dateMatch != "" {
-								timeStr = extractText(cell[1])
-								break
-							}
-						}
-					}
-				}
-			}
-
-			// Convert xklc_view URLs to yxxsxk_index URLs if needed
-			sessionURL := href
-
-			// Extract all parameters from the URL without assuming specific names
-			if strings.Contains(sessionURL, "xklc_view") {
-				// Split the URL to get the path and parameters
-				urlParts := strings.SplitN(sessionURL, "?", 2)
-				if len(urlParts) == 2 {
-					basePath := strings.Replace(urlParts[0], "xklc_view", "yxxsxk_index", 1)
-					sessionURL = basePath + "?" + urlParts[1]
-					fmt.Printf("转换URL: %s => %s\n", href, sessionURL)
-				}
-			} else if strings.Contains(sessionURL, "xsxk_index") {
-				// Also convert any xsxk_index URLs to yxxsxk_index
-				urlParts := strings.SplitN(sessionURL, "?", 2)
-				if len(urlParts) == 2 {
-					basePath := strings.Replace(urlParts[0], "xsxk_index", "yxxsxk_index", 1)
-					sessionURL = basePath + "?" + urlParts[1]
-					fmt.Printf("转换URL: %s => %s\n", href, sessionURL)
-				}
-			}
-
-			// Use the full URL as the key for deduplication
-			sessionKey := sessionURL
-
-			// Extract all parameters for logging purposes only
-			paramPattern := regexp.MustCompile(`([a-zA-Z0-9_]+)=([^&]+)`)
-			paramMatches := paramPattern.FindAllStringSubmatch(sessionURL, -1)
-			for _, paramMatch := range paramMatches {
-				if len(paramMatch) >= 3 {
-					paramName := paramMatch[1]
-					paramValue := paramMatch[2]
-					fmt.Printf("提取到参数: %s=%s\n", paramName, paramValue)
-				}
-			}
-
-			sessionMap[sessionKey] = CourseSession{
-				Term: term,
-				Name: name,
-				Time: timeStr,
-				URL:  sessionURL,
-			}
-		}
-	}
-
-	// Convert map to slice
-	for _, session := range sessionMap {
-		sessions = append(sessions, session)
-	}
-
-	if len(sessions) > 0 {
-		fmt.Printf("\n找到 %d 个唯一的选课会话\n", len(sessions))
-		for i, session := range sessions {
-			fmt.Printf("会话 %d: %s - %s - %s - %s\n", i+1, session.Term, session.Name, session.Time, session.URL)
-		}
-		return sessions, nil
-	}
-
-	// If we still couldn't find any sessions, return an error
-	return nil, fmt.Errorf("无法从响应中提取选课会话信息")
-}
-
-// removeHTMLComments removes HTML comments from a string
-func removeHTMLComments(html string) string {
-	commentPattern := regexp.MustCompile(`<!--[\s\S]*?-->`)
-	return commentPattern.ReplaceAllString(html, "")
-}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Number of catalog snapshots kept on disk, older ones are deleted
+const snapshotsKept = 50
+
+// catalogSnapshot is the course list of a session at one moment, as stored on disk
+type catalogSnapshot struct {
+	Time    time.Time `json:"time"`
+	Term    string    `json:"term"`
+	Session string    `json:"session"`
+	URL     string    `json:"url"` // Entry URL of the session, snapshots of other sessions are not compared
+	Courses []Course  `json:"courses"`
+}
+
+// catalogChange is one difference between two snapshots
+type catalogChange struct {
+	Kind     string `json:"kind"` // opened, removed, seats, teacher or room
+	Kch      string `json:"kch"`
+	Name     string `json:"name"`
+	Jx0404id string `json:"jx0404id"`
+	From     string `json:"from,omitempty"`
+	To       string `json:"to,omitempty"`
+}
+
+// Order in which the kinds of changes are listed
+var changeOrder = map[string]int{"opened": 0, "seats": 1, "teacher": 2, "room": 3, "removed": 4}
+
+// runMonitor reloads the catalog of the selected session at an interval until
+// Ctrl+C, saves every load as a snapshot, records the seat counts for the
+// report command and prints what changed since the previous snapshot,
+// including the latest snapshot of an earlier monitor.
+func runMonitor(args []string) {
+	interval, err := monitorInterval(strings.Join(args, ""))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	dir := snapshotDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Printf("创建快照目录失败: %v\n", err)
+		return
+	}
+
+	previous, err := latestSnapshot(dir, selectedSession.URL)
+	if err != nil {
+		fmt.Printf("读取上一次快照失败: %v\n", err)
+	}
+	if previous != nil {
+		fmt.Printf("将与 %s 的快照比较\n", previous.Time.Local().Format("2006-01-02 15:04:05"))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("开始监控课程列表，每 %v 检查一次，快照保存在 %s，按 Ctrl+C 停止\n", interval, dir)
+	for {
+		wait := interval
+
+		courses, err := loadCourseList(primaryEgress.getCookies())
+		var throttled *throttledError
+		switch {
+		case err == nil:
+			snapshot := &catalogSnapshot{
+				Time:    time.Now(),
+				Term:    selectedSession.Term,
+				Session: selectedSession.Name,
+				URL:     selectedSession.URL,
+				Courses: courses,
+			}
+			path, err := saveSnapshot(dir, snapshot)
+			if err != nil {
+				fmt.Printf("保存快照失败: %v\n", err)
+			}
+			if err := recordSeatHistory(snapshot); err != nil {
+				fmt.Printf("记录余量失败: %v\n", err)
+			}
+
+			if previous == nil {
+				fmt.Printf("%s 已保存第一个快照 (%d 个教学班)\n", snapshot.Time.Format("15:04:05"), len(courses))
+			} else {
+				changes := diffCatalogs(previous.Courses, courses)
+				reportCatalogChanges(snapshot.Time, changes, path)
+			}
+			previous = snapshot
+		case errors.As(err, &throttled):
+			fmt.Printf("正在被限流 (%s)\n", throttled.kind)
+			wait = max(wait, throttled.delay)
+		case errors.Is(err, errSessionExpired):
+			fmt.Println("登录已过期，重新登录...")
+			if err := reloginPrimary(ctx); err != nil {
+				if ctx.Err() != nil {
+					fmt.Println("\n已停止监控")
+				} else {
+					fmt.Printf("已停止监控: %v\n", err)
+				}
+				return
+			}
+		default:
+			fmt.Printf("获取课程列表失败: %v\n", err)
+		}
+
+		if !sleepContext(ctx, wait) {
+			fmt.Println("\n已停止监控")
+			return
+		}
+	}
+}
+
+// diffCatalogs lists the sections opened and removed between two course
+// lists and the changes of seats, teacher and room of the others
+func diffCatalogs(old []Course, current []Course) []catalogChange {
+	before := make(map[string]Course)
+	for _, c := range old {
+		before[c.Jx0404id] = c
+	}
+
+	var changes []catalogChange
+	seen := make(map[string]bool)
+	for _, c := range current {
+		seen[c.Jx0404id] = true
+		change := catalogChange{Kch: c.Kch, Name: c.Kcmc, Jx0404id: c.Jx0404id}
+
+		o, ok := before[c.Jx0404id]
+		if !ok {
+			change.Kind, change.To = "opened", fmt.Sprintf("%s %s 剩余 %s", c.Skls, c.Sksj, c.Syrs)
+			changes = append(changes, change)
+			continue
+		}
+		if o.Syrs != c.Syrs {
+			change.Kind, change.From, change.To = "seats", o.Syrs.String(), c.Syrs.String()
+			changes = append(changes, change)
+		}
+		if o.Skls != c.Skls {
+			change.Kind, change.From, change.To = "teacher", o.Skls, c.Skls
+			changes = append(changes, change)
+		}
+		if o.room() != c.room() {
+			change.Kind, change.From, change.To = "room", o.room(), c.room()
+			changes = append(changes, change)
+		}
+	}
+	for _, o := range old {
+		if !seen[o.Jx0404id] {
+			changes = append(changes, catalogChange{Kind: "removed", Kch: o.Kch, Name: o.Kcmc, Jx0404id: o.Jx0404id})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changeOrder[changes[i].Kind] != changeOrder[changes[j].Kind] {
+			return changeOrder[changes[i].Kind] < changeOrder[changes[j].Kind]
+		}
+		return changes[i].Kch < changes[j].Kch
+	})
+	return changes
+}
+
+// reportCatalogChanges prints the changes of one check and emits them as an
+// event. Newly opened sections and full sections gaining seats ring the bell.
+func reportCatalogChanges(at time.Time, changes []catalogChange, path string) {
+	stamp := at.Format("15:04:05")
+	if len(changes) == 0 {
+		fmt.Printf("%s 没有变化\n", stamp)
+		return
+	}
+
+	emitEvent("catalog_changes", map[string]any{"snapshot": path, "changes": changes})
+
+	fmt.Printf("%s 课程列表有 %d 处变化:\n", stamp, len(changes))
+	alert := false
+	for _, c := range changes {
+		course := c.Kch + " " + c.Name + " (" + c.Jx0404id + ")"
+		switch c.Kind {
+		case "opened":
+			alert = true
+			fmt.Println(colorize("  + 新教学班 "+course+" "+c.To, colorGreen))
+		case "removed":
+			fmt.Println(colorize("  - 已移除 "+course, colorRed))
+		case "seats":
+			line := fmt.Sprintf("  * 剩余量 %s: %s → %s", course, c.From, c.To)
+			if c.From == "0" && c.To != "0" {
+				alert = true
+				fmt.Println(colorize(line+" 有空位了", colorGreen))
+			} else {
+				fmt.Println(line)
+			}
+		case "teacher":
+			fmt.Printf("  * 教师 %s: %s → %s\n", course, c.From, c.To)
+		case "room":
+			fmt.Printf("  * 地点 %s: %s → %s\n", course, c.From, c.To)
+		}
+	}
+	if alert && isTerminal(os.Stdout.Fd()) {
+		fmt.Print("\a")
+	}
+}
+
+// saveSnapshot writes a snapshot into dir, named by its time, deletes the
+// oldest snapshots beyond snapshotsKept and returns the path written
+func saveSnapshot(dir string, snapshot *catalogSnapshot) (string, error) {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "catalog-"+snapshot.Time.Format("20060102-150405")+".json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+
+	files, err := snapshotFiles(dir)
+	if err != nil {
+		return path, nil
+	}
+	for len(files) > snapshotsKept {
+		os.Remove(files[len(files)-1])
+		files = files[:len(files)-1]
+	}
+	return path, nil
+}
+
+// latestSnapshot loads the newest snapshot of a session in dir, or nil if there is none
+func latestSnapshot(dir string, url string) (*catalogSnapshot, error) {
+	files, err := snapshotFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var snapshot catalogSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if snapshot.URL == url {
+			return &snapshot, nil
+		}
+	}
+	return nil, nil
+}
+
+// snapshotFiles lists the snapshot files of dir, newest first
+func snapshotFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "catalog-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	return files, nil
+}
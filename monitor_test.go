@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestDiffCatalogs checks every kind of change and their order
+func TestDiffCatalogs(t *testing.T) {
+	old := []Course{
+		{Kch: "A1", Kcmc: "高等数学", Jx0404id: "1", Skls: "张", Skdd: "101", Syrs: 0},
+		{Kch: "B1", Kcmc: "大学英语", Jx0404id: "2", Skls: "李", Skdd: "202", Syrs: 5},
+		{Kch: "C1", Kcmc: "体育", Jx0404id: "3", Skls: "王", Skdd: "体育馆", Syrs: 10},
+	}
+	current := []Course{
+		{Kch: "A1", Kcmc: "高等数学", Jx0404id: "1", Skls: "张", Skdd: "101", Syrs: 2},
+		{Kch: "B1", Kcmc: "大学英语", Jx0404id: "2", Skls: "赵", Skdd: "203", Syrs: 5},
+		{Kch: "D1", Kcmc: "音乐鉴赏", Jx0404id: "4", Skls: "钱", Sksj: "周三", Syrs: 30},
+	}
+
+	want := []catalogChange{
+		{Kind: "opened", Kch: "D1", Name: "音乐鉴赏", Jx0404id: "4", To: "钱 周三 剩余 30"},
+		{Kind: "seats", Kch: "A1", Name: "高等数学", Jx0404id: "1", From: "0", To: "2"},
+		{Kind: "teacher", Kch: "B1", Name: "大学英语", Jx0404id: "2", From: "李", To: "赵"},
+		{Kind: "room", Kch: "B1", Name: "大学英语", Jx0404id: "2", From: "202", To: "203"},
+		{Kind: "removed", Kch: "C1", Name: "体育", Jx0404id: "3"},
+	}
+	if got := diffCatalogs(old, current); !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+}
+
+// TestDiffCatalogsUnchanged checks that identical catalogs have no changes
+func TestDiffCatalogsUnchanged(t *testing.T) {
+	catalog := []Course{{Kch: "A1", Jx0404id: "1", Syrs: unknownCount}}
+	if got := diffCatalogs(catalog, catalog); len(got) != 0 {
+		t.Errorf("expected no changes, got %+v", got)
+	}
+}
+
+// TestDiffCatalogsRoom checks that rooms are compared as the course table
+// shows them, from the arrangements when 上课地点 is empty
+func TestDiffCatalogsRoom(t *testing.T) {
+	tests := []struct {
+		name      string
+		old, cur  Course
+		from, to  string
+		unchanged bool
+	}{
+		{
+			name: "room only in the arrangements",
+			old:  Course{Jx0404id: "1", KkapList: []KkapInfo{{Jsmc: "教101"}}},
+			cur:  Course{Jx0404id: "1", KkapList: []KkapInfo{{Jsmc: "教205"}}},
+			from: "教101", to: "教205",
+		},
+		{
+			name:      "上课地点 filled in with the same room",
+			old:       Course{Jx0404id: "1", KkapList: []KkapInfo{{Jsmc: "教101"}}},
+			cur:       Course{Jx0404id: "1", Skdd: "教101", KkapList: []KkapInfo{{Jsmc: "教101"}}},
+			unchanged: true,
+		},
+		{
+			name: "arrangements dropped",
+			old:  Course{Jx0404id: "1", Skdd: "体育馆", KkapList: []KkapInfo{{Jsmc: "田径场"}}},
+			cur:  Course{Jx0404id: "1", Skdd: "体育馆"},
+			from: "田径场", to: "体育馆",
+		},
+	}
+	for _, tt := range tests {
+		changes := diffCatalogs([]Course{tt.old}, []Course{tt.cur})
+		if tt.unchanged {
+			if len(changes) != 0 {
+				t.Errorf("%s: expected no changes, got %+v", tt.name, changes)
+			}
+			continue
+		}
+		if len(changes) != 1 || changes[0].Kind != "room" || changes[0].From != tt.from || changes[0].To != tt.to {
+			t.Errorf("%s: got %+v, want room %s → %s", tt.name, changes, tt.from, tt.to)
+		}
+	}
+}
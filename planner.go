@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Planner search limits, keeping the search fast on catalogs with hundreds of
+// courses. The node limit also bounds searches where a later category cannot
+// be met and no combination is ever complete.
+const (
+	planCandidatesPerCategory = 12
+	planMaxCombinations       = 500
+	planMaxNodes              = 50000
+	planOptionsShown          = 5
+)
+
+// planOption is one conflict-free combination of courses meeting the requirements
+type planOption struct {
+	courses []Course
+	score   float64
+}
+
+// planCourse is a course with its time slots, parsed once for the search
+type planCourse struct {
+	Course
+	slots map[timeSlot]struct{}
+	score float64
+}
+
+// planner searches the catalog for course combinations that cover category requirements
+type planner struct {
+	needs    map[string]categoryLimit
+	names    []string // Requirement names in a stable order
+	days     map[int]bool
+	periods  map[int]bool
+	fixed    []Course // Courses already chosen, new picks must not conflict with them
+	options  []planOption
+	searched int // Complete combinations found
+	visited  int // Search nodes visited
+}
+
+// newPlanner creates a planner for the requirements, avoiding conflicts with fixed courses
+func newPlanner(needs map[string]categoryLimit, fixed []Course) *planner {
+	p := &planner{
+		needs:   needs,
+		days:    make(map[int]bool),
+		periods: make(map[int]bool),
+		fixed:   fixed,
+	}
+	for name := range needs {
+		p.names = append(p.names, name)
+	}
+	sort.Strings(p.names)
+
+	for _, day := range parseWeekRanges(profile.PreferDays) {
+		p.days[day] = true
+	}
+	for _, period := range parseWeekRanges(profile.PreferPeriods) {
+		p.periods[period] = true
+	}
+	return p
+}
+
+// seats returns the remaining seats of a course, 0 if unknown
+func seats(c Course) int {
+	if !c.Syrs.known() {
+		return 0
+	}
+	return int(c.Syrs)
+}
+
+// mayHaveSeats reports whether a course has seats left or does not say
+func mayHaveSeats(c Course) bool {
+	return c.Syrs != 0
+}
+
+// newPlanCourse parses the time slots of a course and scores it
+func (p *planner) newPlanCourse(c Course) planCourse {
+	pc := planCourse{Course: c, slots: courseSlots(c)}
+	pc.score = p.courseScore(c, pc.slots)
+	return pc
+}
+
+// courseScore rates a course by remaining seats and how well its time slots
+// match the preferred weekdays and periods. Each part contributes up to 1.
+func (p *planner) courseScore(c Course, slots map[timeSlot]struct{}) float64 {
+	n := seats(c)
+	if n > 30 {
+		n = 30
+	}
+	score := float64(n) / 30
+
+	if len(slots) == 0 {
+		return score
+	}
+
+	var dayHits, periodHits int
+	for slot := range slots {
+		if p.days[slot.weekday] {
+			dayHits++
+		}
+		if p.periods[slot.period] {
+			periodHits++
+		}
+	}
+	if len(p.days) > 0 {
+		score += float64(dayHits) / float64(len(slots))
+	}
+	if len(p.periods) > 0 {
+		score += float64(periodHits) / float64(len(slots))
+	}
+	return score
+}
+
+// candidates returns the best scoring courses of a requirement category that
+// are not known to be full
+func (p *planner) candidates(name string, catalog []Course) []planCourse {
+	var list []planCourse
+	for _, c := range catalog {
+		if strings.Contains(c.Szkcflmc, name) && mayHaveSeats(c) {
+			list = append(list, p.newPlanCourse(c))
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].score > list[j].score
+	})
+	if len(list) > planCandidatesPerCategory {
+		list = list[:planCandidatesPerCategory]
+	}
+	return list
+}
+
+// plan returns the best combinations, highest score first
+func (p *planner) plan(catalog []Course) []planOption {
+	perCategory := make([][]planCourse, len(p.names))
+	for i, name := range p.names {
+		perCategory[i] = p.candidates(name, catalog)
+	}
+
+	var fixed []planCourse
+	for _, c := range p.fixed {
+		fixed = append(fixed, p.newPlanCourse(c))
+	}
+	p.search(perCategory, 0, 0, fixed, nil, 0, 0)
+
+	sort.SliceStable(p.options, func(i, j int) bool {
+		return p.options[i].score > p.options[j].score
+	})
+	if len(p.options) > planOptionsShown {
+		p.options = p.options[:planOptionsShown]
+	}
+	return p.options
+}
+
+// search picks courses category by category. Within a category it only
+// extends a combination until the requirement is met, so every result is a
+// minimal set of courses for that category.
+func (p *planner) search(perCategory [][]planCourse, category int, from int, chosen []planCourse, picked []planCourse, credits float64, count int) {
+	if p.searched >= planMaxCombinations || p.visited >= planMaxNodes {
+		return
+	}
+	p.visited++
+
+	if category == len(p.names) {
+		p.searched++
+		var total float64
+		courses := make([]Course, 0, len(picked))
+		for _, c := range picked {
+			total += c.score
+			courses = append(courses, c.Course)
+		}
+		score := 0.0
+		if len(picked) > 0 {
+			score = total / float64(len(picked))
+		}
+		p.options = append(p.options, planOption{courses: courses, score: score})
+		return
+	}
+
+	need := p.needs[p.names[category]]
+	if credits >= need.Credits && count >= need.Courses {
+		p.search(perCategory, category+1, 0, chosen, picked, 0, 0)
+		return
+	}
+
+	list := perCategory[category]
+	for i := from; i < len(list); i++ {
+		c := list[i]
+		if conflictsWithAny(c, chosen) {
+			continue
+		}
+		p.search(perCategory, category, i+1, append(chosen, c), append(picked, c), credits+float64(c.Xf), count+1)
+	}
+}
+
+// conflictsWithAny reports whether c is the same course as, or meets at the
+// same time as, any of the chosen courses
+func conflictsWithAny(c planCourse, chosen []planCourse) bool {
+	for _, other := range chosen {
+		if other.Kch == c.Kch || slotsOverlap(c.slots, other.slots) {
+			return true
+		}
+	}
+	return false
+}
+
+// printPlanOptions shows the combinations found by the planner
+func printPlanOptions(options []planOption) {
+	if len(options) == 0 {
+		fmt.Println("没有找到满足要求且不冲突的课程组合")
+		return
+	}
+
+	for i, option := range options {
+		fmt.Printf("\n方案 %d (评分 %.2f):\n", i+1, option.score)
+		tbl := newTable(
+			tableColumn{title: "课程编号", min: 8},
+			tableColumn{title: "课程名称", min: 8, max: 24, wrap: true},
+			tableColumn{title: "类别", min: 6, max: 16},
+			tableColumn{title: "学分", min: 4},
+			tableColumn{title: "上课时间", min: 10, max: 24, wrap: true},
+			tableColumn{title: "剩余量", min: 6, color: seatsColor},
+		)
+		for _, c := range option.courses {
+			tbl.addRow(c.Kch, c.Kcmc, c.Szkcflmc, c.Xf.String(), c.Sksj, c.Syrs.String())
+		}
+		tbl.render()
+	}
+}
+
+// planCourses runs the planner for a requirement such as "人文科学=2,艺术=2"
+// and returns the sections of the combination the user picks
+func planCourses(requirement string, selected []Course) []Course {
+	needs, err := parseCategoryLimits(requirement)
+	if err != nil || len(needs) == 0 {
+		fmt.Println("规划要求格式错误，应为 类别=学分[:门数],...")
+		return nil
+	}
+
+	catalog := append([]Course(nil), courseCatalog...)
+	sort.SliceStable(catalog, func(i, j int) bool { return catalog[i].Kch < catalog[j].Kch })
+
+	options := newPlanner(needs, selected).plan(catalog)
+	printPlanOptions(options)
+	if len(options) == 0 {
+		return nil
+	}
+
+	input := readInput("\n选择方案编号加入选课篮 (直接回车跳过): ")
+	if input == "" {
+		return nil
+	}
+
+	index, err := strconv.Atoi(input)
+	if err != nil || index < 1 || index > len(options) {
+		fmt.Println("无效的方案编号")
+		return nil
+	}
+	return options[index-1].courses
+}
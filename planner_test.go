@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// plannerCourse returns a one-credit course of a category meeting once a week
+func plannerCourse(kch string, category string, weekday int, period int) Course {
+	return Course{
+		Kch:        kch,
+		Kcmc:       kch,
+		Szkcflmc:   category,
+		Xf:         1,
+		Syrs:       10,
+		ZcxqjcList: []ZcxqjcInfo{{Zc: "1", Xq: fmt.Sprint(weekday), Jc: fmt.Sprint(period)}},
+	}
+}
+
+// TestPlannerFindsConflictFreeCombination checks that the planner meets every
+// category without picking courses that meet at the same time
+func TestPlannerFindsConflictFreeCombination(t *testing.T) {
+	catalog := []Course{
+		plannerCourse("A1", "人文科学", 1, 1),
+		plannerCourse("A2", "人文科学", 2, 1),
+		plannerCourse("B1", "艺术", 1, 1), // Conflicts with A1
+		plannerCourse("B2", "艺术", 3, 1),
+	}
+	needs := map[string]categoryLimit{"人文科学": {Credits: 2}, "艺术": {Credits: 1}}
+
+	options := newPlanner(needs, nil).plan(catalog)
+	if len(options) == 0 {
+		t.Fatal("no combination found")
+	}
+	for _, option := range options {
+		for i, a := range option.courses {
+			for _, b := range option.courses[i+1:] {
+				if coursesConflict(a, b) {
+					t.Errorf("%s and %s conflict in %v", a.Kch, b.Kch, option.courses)
+				}
+			}
+		}
+	}
+}
+
+// TestPlannerBoundsImpossibleSearch checks that a category nobody can meet
+// does not make the search walk every combination of the earlier ones
+func TestPlannerBoundsImpossibleSearch(t *testing.T) {
+	var catalog []Course
+	for i := 0; i < planCandidatesPerCategory; i++ {
+		catalog = append(catalog, plannerCourse(fmt.Sprintf("A%02d", i), "人文科学", i%7+1, i/7+1))
+	}
+	for i := 0; i < planCandidatesPerCategory; i++ {
+		catalog = append(catalog, plannerCourse(fmt.Sprintf("B%02d", i), "艺术", i%7+1, i/7+3))
+	}
+	// Twelve 艺术 candidates can never make thirteen courses
+	needs := map[string]categoryLimit{"人文科学": {Courses: 6}, "艺术": {Courses: planCandidatesPerCategory + 1}}
+
+	p := newPlanner(needs, nil)
+	started := time.Now()
+	if options := p.plan(catalog); len(options) != 0 {
+		t.Errorf("expected no combination, got %d", len(options))
+	}
+	if p.visited > planMaxNodes {
+		t.Errorf("visited %d nodes, limit is %d", p.visited, planMaxNodes)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("search took %v", elapsed)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Profile holds per-school and per-user settings loaded from a JSON file
+type Profile struct {
+	BaseURL     string `json:"baseURL"`     // 教务系统地址, 默认 https://jw.educationgroup.cn/ytkjxy_jsxsd
+	LoginMethod string `json:"loginMethod"` // 登录方式: loginToXk (默认, base64 编码) 或 sess (Logon.do 加密登录)
+
+	TermStart  string   `json:"termStart"`  // 第一教学周周一日期, 例如 2025-09-01
+	Proxy      string   `json:"proxy"`      // 代理地址 (http/https/socks5)，"direct" 表示不使用环境代理
+	ProxyPool  []string `json:"proxyPool"`  // 选课请求分散使用的代理列表
+	SourceAddr string   `json:"sourceAddr"` // 绑定的本地源地址
+
+	RateLimit      float64 `json:"rateLimit"`      // 所有课程合计每秒请求数, 0 使用默认值, 负数表示不限制
+	CourseInterval string  `json:"courseInterval"` // 单门课程两次请求的最小间隔, 例如 "1s"
+
+	StartAt string `json:"startAt"` // 定时开始选课的服务器时间, 例如 "2025-06-25 12:00:00"
+
+	BurstDuration    string `json:"burstDuration"`    // 定时开始后的爆发阶段时长, 例如 "5s", 为空则不启用
+	BurstParallel    int    `json:"burstParallel"`    // 爆发阶段每门课程保持的并发请求数
+	BurstMaxInFlight int    `json:"burstMaxInFlight"` // 爆发阶段全局最多同时进行的请求数
+
+	MaxCredits     float64                  `json:"maxCredits"`     // 本次最多选的学分, 0 表示不限制
+	MaxCourses     int                      `json:"maxCourses"`     // 本次最多选的门数, 0 表示不限制
+	CategoryLimits map[string]categoryLimit `json:"categoryLimits"` // 按通选课类别的学分/门数限制
+
+	PreferDays    string `json:"preferDays"`    // 规划时偏好的星期, 例如 "1-3,5"
+	PreferPeriods string `json:"preferPeriods"` // 规划时偏好的节次, 例如 "1-4,9-10"
+
+	SessionFilter string     `json:"sessionFilter"` // 等待名称或学期包含该文字的选课会话发布
+	SessionPoll   string     `json:"sessionPoll"`   // 等待选课会话时的查询间隔, 例如 "30s"
+	Courses       []string   `json:"courses"`       // 进入会话后自动加入选课篮的课程号或选课ID, 按优先级排列
+	Bundles       [][]string `json:"bundles"`       // 必须同时选上的课程组, 每组为课程号或选课ID, 一门选不上则退选组内其余课程
+	AutoGo        bool       `json:"autoGo"`        // 自动进入会话后立即开始选课
+
+	CatalogRefresh string   `json:"catalogRefresh"` // 选课期间后台刷新课程列表的间隔, 例如 "10m", 为空则不刷新
+	Watch          []string `json:"watch"`          // 刷新时提示新开放的匹配教学班, 每项为空格分隔的关键词, 与 list 命令相同
+
+	MonitorInterval string `json:"monitorInterval"` // monitor 命令检查课程列表的间隔, 默认 5m
+	SnapshotDir     string `json:"snapshotDir"`     // 课程列表快照保存目录, 默认 snapshots
+	HistoryFile     string `json:"historyFile"`     // monitor 记录余量变化的 JSONL 文件, 默认 snapshots/seats.jsonl
+
+	CaptchaFile  string `json:"captchaFile"`  // 验证码图片保存路径, 默认保存到临时目录
+	CaptchaField string `json:"captchaField"` // 提交验证码的表单字段, 默认 RANDOMCODE
+}
+
+// Global profile, filled from the profile file and command line flags
+var profile Profile
+
+// loadProfile reads a JSON profile from disk
+func loadProfile(path string) (Profile, error) {
+	var p Profile
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("配置文件格式错误: %v", err)
+	}
+
+	return p, nil
+}
+
+// Default address of the QZ system of the school this tool was written for
+const defaultBaseURL = "https://jw.educationgroup.cn/ytkjxy_jsxsd"
+
+// baseURL returns the address of the QZ system without a trailing slash
+func baseURL() string {
+	if profile.BaseURL == "" {
+		return defaultBaseURL
+	}
+	return strings.TrimRight(profile.BaseURL, "/")
+}
+
+// checkBaseURL validates the configured address of the QZ system
+func checkBaseURL() error {
+	u, err := url.Parse(baseURL())
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("教务系统地址格式错误，应为 https://主机/路径，例如 %s", defaultBaseURL)
+	}
+	return nil
+}
+
+// siteURL returns the URL of a page of the QZ system, path starting with "/"
+func siteURL(path string) string {
+	return baseURL() + path
+}
+
+// siteOrigin returns the scheme and host of the QZ system, for links that
+// the pages give as absolute paths
+func siteOrigin() string {
+	u, err := url.Parse(baseURL())
+	if err != nil {
+		return baseURL()
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// siteHost returns the host name of the QZ system
+func siteHost() string {
+	u, err := url.Parse(baseURL())
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// termStartDate parses the configured term start and returns the Monday of week 1
+func termStartDate() (time.Time, error) {
+	if profile.TermStart == "" {
+		return time.Time{}, fmt.Errorf("未设置学期开始日期，请使用 -term-start 或在配置文件中设置 termStart")
+	}
+
+	start, err := time.ParseInLocation("2006-01-02", profile.TermStart, chinaTime)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("学期开始日期格式错误，应为 YYYY-MM-DD: %v", err)
+	}
+
+	// Move back to Monday so that week numbers always start on a Monday
+	offset := (int(start.Weekday()) + 6) % 7
+	return start.AddDate(0, 0, -offset), nil
+}
+
+// courseInterval returns the configured minimum interval between requests of one course
+func courseInterval() (time.Duration, error) {
+	if profile.CourseInterval == "" {
+		return defaultCourseInterval, nil
+	}
+
+	d, err := time.ParseDuration(profile.CourseInterval)
+	if err != nil {
+		return 0, fmt.Errorf("课程请求间隔格式错误，应为 500ms、1s 等: %v", err)
+	}
+	return d, nil
+}
+
+// rateLimit returns the configured total request rate, 0 meaning unlimited
+func rateLimit() float64 {
+	switch {
+	case profile.RateLimit == 0:
+		return defaultRateLimit
+	case profile.RateLimit < 0:
+		return 0
+	}
+	return profile.RateLimit
+}
+
+// scheduledStart parses the configured start time, returning the zero time if none is set
+func scheduledStart() (time.Time, error) {
+	if profile.StartAt == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range []string{"2006-01-02 15:04:05.000", "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, profile.StartAt, chinaTime); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("开始时间格式错误，应为 YYYY-MM-DD HH:MM:SS")
+}
+
+// Default interval between two looks at the session list while waiting for a session
+const defaultSessionPoll = 30 * time.Second
+
+// sessionPoll returns the interval between session list polls
+func sessionPoll() (time.Duration, error) {
+	if profile.SessionPoll == "" {
+		return defaultSessionPoll, nil
+	}
+
+	d, err := time.ParseDuration(profile.SessionPoll)
+	if err != nil || d < 5*time.Second {
+		return 0, fmt.Errorf("会话查询间隔格式错误，应为不小于 5s 的时长，例如 30s")
+	}
+	return d, nil
+}
+
+// catalogRefresh returns the interval between background catalog refreshes
+// during a run, 0 meaning disabled
+func catalogRefresh() (time.Duration, error) {
+	if profile.CatalogRefresh == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(profile.CatalogRefresh)
+	if err != nil || d < 30*time.Second {
+		return 0, fmt.Errorf("课程列表刷新间隔格式错误，应为不小于 30s 的时长，例如 10m")
+	}
+	return d, nil
+}
+
+// Default interval and snapshot directory of the monitor command
+const (
+	defaultMonitorInterval = 5 * time.Minute
+	defaultSnapshotDir     = "snapshots"
+)
+
+// monitorInterval parses an interval for the monitor command, falling back
+// to the profile and then the default when value is empty
+func monitorInterval(value string) (time.Duration, error) {
+	if value == "" {
+		value = profile.MonitorInterval
+	}
+	if value == "" {
+		return defaultMonitorInterval, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 30*time.Second {
+		return 0, fmt.Errorf("监控间隔格式错误，应为不小于 30s 的时长，例如 5m")
+	}
+	return d, nil
+}
+
+// snapshotDir returns the directory that keeps the catalog snapshots
+func snapshotDir() string {
+	if profile.SnapshotDir == "" {
+		return defaultSnapshotDir
+	}
+	return profile.SnapshotDir
+}
+
+// Defaults for the opening burst phase
+const (
+	defaultBurstParallel    = 3
+	defaultBurstMaxInFlight = 20
+)
+
+// burstDuration returns the length of the burst phase, 0 meaning disabled
+func burstDuration() (time.Duration, error) {
+	if profile.BurstDuration == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(profile.BurstDuration)
+	if err != nil {
+		return 0, fmt.Errorf("爆发阶段时长格式错误，应为 5s、10s 等: %v", err)
+	}
+	return d, nil
+}
+
+// burstParallel returns how many requests each course keeps in flight during the burst
+func burstParallel() int {
+	if profile.BurstParallel <= 0 {
+		return defaultBurstParallel
+	}
+	return profile.BurstParallel
+}
+
+// burstMaxInFlight returns the global cap on burst requests in flight
+func burstMaxInFlight() int {
+	if profile.BurstMaxInFlight <= 0 {
+		return defaultBurstMaxInFlight
+	}
+	return profile.BurstMaxInFlight
+}
+
+// chinaTime is the fixed UTC+8 zone used by the school, avoiding a tzdata dependency
+var chinaTime = time.FixedZone("CST", 8*3600)
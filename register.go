@@ -0,0 +1,851 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// courseTarget is one course a worker is trying to register for
+type courseTarget struct {
+	kch      string
+	name     string
+	jx0404id string // Section the target was created for, its key within the run
+	priority int    // Higher values get more of the request budget
+
+	mu          sync.Mutex
+	state       string // 等待, 选课中, 成功, 成功(未确认), 失败, 已取消, 已跳过, 已持有 or 已退选
+	attempts    int
+	lastMessage string
+	lastLatency time.Duration
+	lastOK      time.Time // Last time the server gave a well-formed answer
+	confirmed   bool      // Success was seen in the 已选课程 list
+	section     Course    // Section requested, replaced when the school reissues its jx0404id
+}
+
+// currentSection returns the section the target is requesting
+func (t *courseTarget) currentSection() Course {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.section
+}
+
+// setSection replaces the section the target is requesting
+func (t *courseTarget) setSection(c Course) {
+	t.mu.Lock()
+	t.section = c
+	t.mu.Unlock()
+}
+
+// setState records the state of a target for the status command
+func (t *courseTarget) setState(state string) {
+	t.mu.Lock()
+	t.state = state
+	t.mu.Unlock()
+}
+
+// setConfirmed records whether a success was seen in the 已选课程 list
+func (t *courseTarget) setConfirmed(confirmed bool) {
+	t.mu.Lock()
+	t.confirmed = confirmed
+	t.mu.Unlock()
+}
+
+// record stores the number and answer of the latest attempt and emits it as
+// an event together with the raw response, if there was one
+func (t *courseTarget) record(attempt int, message string, response []byte) {
+	t.mu.Lock()
+	if attempt > t.attempts {
+		t.attempts = attempt
+	}
+	t.lastMessage = message
+	latency := t.lastLatency
+	t.mu.Unlock()
+
+	emitEvent("attempt", map[string]any{
+		"kch":        t.kch,
+		"jx0404id":   t.jx0404id,
+		"attempt":    attempt,
+		"message":    message,
+		"response":   string(response),
+		"latency_ms": latency.Milliseconds(),
+	})
+}
+
+// observe stores the latency of the latest response and whether the server
+// answered it normally, as opposed to throttling or garbling it
+func (t *courseTarget) observe(latency time.Duration, ok bool) {
+	t.mu.Lock()
+	t.lastLatency = latency
+	if ok {
+		t.lastOK = time.Now()
+	}
+	t.mu.Unlock()
+}
+
+// targetStatus is a consistent copy of the progress of a target
+type targetStatus struct {
+	state       string
+	attempts    int
+	lastMessage string
+	lastLatency time.Duration
+	lastOK      time.Time
+	confirmed   bool
+}
+
+// snapshot returns a copy of the progress of a target
+func (t *courseTarget) snapshot() targetStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return targetStatus{
+		state:       t.state,
+		attempts:    t.attempts,
+		lastMessage: t.lastMessage,
+		lastLatency: t.lastLatency,
+		lastOK:      t.lastOK,
+		confirmed:   t.confirmed,
+	}
+}
+
+// Targets of the latest run, shown by the status command
+var lastRun []*courseTarget
+
+// attemptOutcome tells a worker what to do after one selection request
+type attemptOutcome int
+
+const (
+	outcomeRetry    attemptOutcome = iota // Not selected yet, try again
+	outcomeSuccess                        // Course selected
+	outcomeSelected                       // Server says the course is already selected
+	outcomeTerminal                       // Server refused for a reason retrying cannot fix
+	outcomeStopped                        // The user stopped after the credentials were rejected
+)
+
+// Messages after which retrying the same course is pointless. A full class
+// reaches a limit too (选课人数已达上限), so only credit and course count
+// limits are final.
+var terminalMarkers = []string{
+	"冲突", "学分已达上限", "学分超出上限", "门数已达上限", "门数超出上限",
+	"不能选", "不允许", "无权", "不符合",
+}
+
+// Messages of a refusal because the section is already held. A bare 已选 is not
+// enough: full-class answers such as 已选满 and 已选人数已达上限 contain it too.
+var selectedMarkers = []string{
+	"该课程已选", "已选过", "已经选", "已选择该", "不能重复选", "重复选课",
+}
+
+// isSelectedMessage reports whether a selection failure message says the
+// section is already held
+func isSelectedMessage(msg string) bool {
+	for _, marker := range selectedMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTerminalMessage reports whether a selection failure message is final
+func isTerminalMessage(msg string) bool {
+	for _, marker := range terminalMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// registerForCourses registers for the courses in priority order until each
+// one succeeds, fails for good or ctx is cancelled, and returns the courses
+// that were selected. Sections of the same course code are alternatives:
+// courses already held are skipped, and the first section selected stops
+// the others. Bundles list jx0404ids that must be held together: when one
+// member fails for good, the others stop and those selected in this run are
+// dropped again. If start is set and a burst phase is configured, every
+// course keeps several requests in flight for the first seconds after start
+// before falling back to normal pacing.
+func registerForCourses(ctx context.Context, courses []Course, bundles [][]string, cookies []*http.Cookie, start time.Time) []Course {
+	// Rejected credentials stop the whole run if no new ones are given
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	logins.startRun(cancel)
+	defer logins.endRun()
+
+	var wg sync.WaitGroup
+	successChan := make(chan *courseTarget)
+	failChan := make(chan *courseTarget)
+	doneChan := make(chan bool)
+	var successfulCourses []Course
+	var successfulTargets []*courseTarget
+	summaryDone := make(chan struct{})
+
+	// The primary egress already holds the interactive session
+	primaryEgress.setCookies(cookies)
+
+	burstUntil := time.Time{}
+	if burst, _ := burstDuration(); !start.IsZero() && burst > 0 {
+		burstUntil = start.Add(burst)
+		fmt.Printf("爆发阶段: 开始后 %v 内每门课程保持 %d 个并发请求，全局最多 %d 个\n",
+			burst, burstParallel(), burstMaxInFlight())
+	}
+
+	// Cancel functions of running workers keyed by jx0404id, used to stop
+	// courses that no longer fit the budget
+	budget := newCreditBudget()
+	var workersMu sync.Mutex
+	workers := make(map[string]context.CancelFunc)
+	sections := make(map[string]Course)
+	for _, c := range courses {
+		sections[c.Jx0404id] = c
+	}
+
+	lastRun = nil
+	targets := make(map[string]*courseTarget)
+	for i, course := range courses {
+		lastRun = append(lastRun, &courseTarget{kch: course.Kch, name: course.Kcmc, jx0404id: course.Jx0404id,
+			priority: len(courses) - i, state: "等待", section: course})
+		targets[course.Jx0404id] = lastRun[i]
+	}
+
+	// Bundle of each bundled jx0404id, and the bundles given up. The start
+	// loop and the collector both use failedBundles, so it is guarded by
+	// workersMu: a bundle given up while its members are being started
+	// either finds the worker to cancel or keeps it from starting.
+	bundleOf := make(map[string]int)
+	for i, b := range bundles {
+		for _, id := range b {
+			bundleOf[id] = i
+		}
+	}
+	failedBundles := make(map[int]bool)
+	bundleFailed := func(id string) bool {
+		workersMu.Lock()
+		defer workersMu.Unlock()
+		b, ok := bundleOf[id]
+		return ok && failedBundles[b]
+	}
+
+	// Start a goroutine to collect successful registrations
+	go func() {
+		defer close(summaryDone)
+		confirmedIDs := make(map[string]bool)
+
+		// failBundle gives up a bundle: members still trying stop and members
+		// selected in this run are dropped, so that no half stays behind
+		failBundle := func(b int, failed *courseTarget) {
+			workersMu.Lock()
+			if failedBundles[b] {
+				workersMu.Unlock()
+				return
+			}
+			failedBundles[b] = true
+			for _, id := range bundles[b] {
+				if cancel, ok := workers[id]; ok {
+					cancel()
+					delete(workers, id)
+				}
+			}
+			workersMu.Unlock()
+			logf("⚠️  捆绑课程 %s 未能选上，放弃整组: %s\n", failed.kch, strings.Join(bundles[b], ", "))
+
+			var dropped []string
+			keptCourses, keptTargets := successfulCourses[:0], successfulTargets[:0]
+			for i, st := range successfulTargets {
+				if member, ok := bundleOf[st.jx0404id]; ok && member == b && dropBundleMember(st) {
+					dropped = append(dropped, st.jx0404id)
+					continue
+				}
+				keptCourses = append(keptCourses, successfulCourses[i])
+				keptTargets = append(keptTargets, st)
+			}
+			successfulCourses, successfulTargets = keptCourses, keptTargets
+			emitEvent("bundle_failed", map[string]any{"failed": failed.jx0404id, "members": bundles[b], "dropped": dropped})
+		}
+
+		for {
+			select {
+			case t := <-failChan:
+				failBundle(bundleOf[t.jx0404id], t)
+			case t := <-successChan:
+				course := t.currentSection()
+				if bundleFailed(t.jx0404id) {
+					// Selected just after its bundle was given up
+					if !dropBundleMember(t) {
+						successfulCourses = append(successfulCourses, course)
+						successfulTargets = append(successfulTargets, t)
+					}
+					continue
+				}
+				successfulCourses = append(successfulCourses, course)
+				successfulTargets = append(successfulTargets, t)
+				confirmed := t.snapshot().confirmed
+				confirmedIDs[course.Jx0404id] = confirmed
+				logf("课程 %s 选课成功! (%s)\n", course.Kch, confirmationLabel(confirmed))
+				emitEvent("success", map[string]any{"kch": course.Kch, "jx0404id": course.Jx0404id, "name": course.Kcmc,
+					"confirmed": confirmed})
+
+				// Any section of a course code satisfies its other sections
+				workersMu.Lock()
+				for id, cancel := range workers {
+					if id != t.jx0404id && sections[id].Kch == course.Kch {
+						logf("课程 %s 已选上教学班 %s，取消教学班 %s\n", course.Kch, course.Jx0404id, id)
+						cancel()
+						delete(workers, id)
+					}
+				}
+				workersMu.Unlock()
+
+				if !budget.enabled() {
+					continue
+				}
+				budget.commit(course)
+				logf("已用额度: %s\n", budget.summary())
+
+				// Stop the remaining courses that would now break a limit
+				var overBudget []string
+				workersMu.Lock()
+				for id, cancel := range workers {
+					if reason := budget.reason(sections[id]); reason != "" {
+						logf("课程 %s 将超出%s，取消该课程的选课请求\n", sections[id].Kch, reason)
+						cancel()
+						delete(workers, id)
+						overBudget = append(overBudget, id)
+					}
+				}
+				workersMu.Unlock()
+
+				// A bundle missing a member can no longer complete
+				for _, id := range overBudget {
+					if b, ok := bundleOf[id]; ok {
+						failBundle(b, targets[id])
+					}
+				}
+			case <-doneChan:
+				emitSummary(successfulCourses)
+				fmt.Println("\n选课结果汇总:")
+				if len(successfulCourses) > 0 {
+					fmt.Println("成功选上的课程:")
+					for _, course := range successfulCourses {
+						fmt.Printf("- %s %s [%s]\n", course.Kch, course.Kcmc, confirmationLabel(confirmedIDs[course.Jx0404id]))
+					}
+				} else {
+					fmt.Println("没有成功选上任何课程")
+				}
+				return
+			}
+		}
+	}()
+
+	if budget.enabled() {
+		fmt.Printf("选课额度限制: %s\n", budget.summary())
+		checkBudgetPlan(courses)
+	}
+
+	// Courses held from an earlier run would only collect 已选 answers
+	held, err := heldSections(primaryEgress, courses)
+	if err != nil {
+		fmt.Printf("读取已选课程失败，不跳过已选的课程: %v\n", err)
+	}
+
+	// A bundle with a member over the budget cannot complete at all
+	for _, course := range courses {
+		if b, ok := bundleOf[course.Jx0404id]; ok && budget.reason(course) != "" {
+			if _, isHeld := held[course.Jx0404id]; isHeld {
+				continue
+			}
+			workersMu.Lock()
+			failedBundles[b] = true
+			workersMu.Unlock()
+		}
+	}
+
+	// Show the live dashboard on a terminal, plain log lines otherwise
+	stats.requests.Store(0)
+	stats.relogins.Store(0)
+	stats.errors.Store(0)
+	dash := startDashboard(lastRun)
+
+	// Start a goroutine for each course
+	for i, course := range courses {
+		t := lastRun[i]
+		if h, ok := held[course.Jx0404id]; ok {
+			logf("课程 %s 已选教学班 %s，已跳过\n", course.Kch, h.Jx0404id)
+			t.setState("已持有")
+			continue
+		}
+		if reason := budget.reason(course); reason != "" {
+			logf("课程 %s 单独选择就会超出%s，已跳过\n", course.Kch, reason)
+			t.setState("已跳过")
+			continue
+		}
+
+		workerCtx, cancel := context.WithCancel(ctx)
+		workersMu.Lock()
+		if b, ok := bundleOf[course.Jx0404id]; ok && failedBundles[b] {
+			workersMu.Unlock()
+			cancel()
+			logf("课程 %s 所在的捆绑课程组无法全部选上，已跳过\n", course.Kch)
+			t.setState("已跳过")
+			continue
+		}
+		workers[t.jx0404id] = cancel
+		workersMu.Unlock()
+
+		wg.Add(1)
+		go func(t *courseTarget, e *egress) {
+			defer wg.Done()
+			defer func() {
+				workersMu.Lock()
+				if cancel, ok := workers[t.jx0404id]; ok {
+					cancel()
+					delete(workers, t.jx0404id)
+				}
+				workersMu.Unlock()
+			}()
+
+			t.setState("选课中")
+			switch {
+			case runCourseWorker(workerCtx, t, e, burstUntil):
+				if t.snapshot().confirmed {
+					t.setState("成功")
+				} else {
+					t.setState("成功(未确认)")
+				}
+				successChan <- t
+			case workerCtx.Err() != nil:
+				t.setState("已取消")
+			default:
+				t.setState("失败")
+				if _, ok := bundleOf[t.jx0404id]; ok {
+					failChan <- t
+				}
+			}
+		}(t, egressFor(i))
+	}
+
+	// Keep the catalog fresh during long runs
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	watchDone := make(chan struct{})
+	if interval, _ := catalogRefresh(); interval > 0 {
+		go func() {
+			defer close(watchDone)
+			watchCatalog(watchCtx, lastRun, interval)
+		}()
+	} else {
+		close(watchDone)
+	}
+
+	// Wait for all goroutines to finish
+	wg.Wait()
+	stopWatch()
+	<-watchDone
+	dash.close()
+	doneChan <- true
+	<-summaryDone
+
+	if icsPath != "" {
+		if err := exportICS(icsPath, timetableCourses(successfulCourses)); err != nil {
+			fmt.Printf("导出日历失败: %v\n", err)
+		} else {
+			fmt.Printf("课表已导出到 %s\n", icsPath)
+		}
+	}
+	return successfulCourses
+}
+
+// dropBundleMember withdraws a selected member of a bundle that was given up
+// and reports whether it was dropped
+func dropBundleMember(t *courseTarget) bool {
+	id := t.currentSection().Jx0404id
+	err := dropCourse(primaryEgress, id)
+	emitEvent("drop", map[string]any{"kch": t.kch, "jx0404id": id, "ok": err == nil, "error": errorText(err), "bundle": true})
+	if err != nil {
+		logf("⚠️  退选捆绑课程 %s (%s) 失败，请手动退选: %v\n", t.kch, id, err)
+		return false
+	}
+	t.setState("已退选")
+	logf("已退选捆绑课程 %s (%s)\n", t.kch, id)
+	return true
+}
+
+// confirmationLabel describes whether a success was seen in the 已选课程 list
+func confirmationLabel(confirmed bool) string {
+	if confirmed {
+		return "已确认"
+	}
+	return "未确认"
+}
+
+// emitSummary emits the final state of every target of the run
+func emitSummary(selected []Course) {
+	var ids []string
+	for _, c := range selected {
+		ids = append(ids, c.Jx0404id)
+	}
+
+	var targets []map[string]any
+	for _, t := range lastRun {
+		st := t.snapshot()
+		targets = append(targets, map[string]any{
+			"kch":          t.kch,
+			"jx0404id":     t.jx0404id,
+			"name":         t.name,
+			"state":        st.state,
+			"attempts":     st.attempts,
+			"last_message": st.lastMessage,
+			"confirmed":    st.confirmed,
+		})
+	}
+
+	emitEvent("summary", map[string]any{
+		"selected": ids,
+		"targets":  targets,
+		"requests": stats.requests.Load(),
+		"relogins": stats.relogins.Load(),
+		"errors":   stats.errors.Load(),
+	})
+}
+
+// checkBudgetPlan warns when the selected courses, taken in priority order,
+// add up to more than the budget allows. The extra courses still run as
+// backups and are cancelled once higher priority courses fill the budget.
+func checkBudgetPlan(courses []Course) {
+	plan := newCreditBudget()
+	var backups []string
+	planned := make(map[string]bool)
+	for _, course := range courses {
+		// Other sections of a planned course are alternatives, not extra credits
+		if planned[course.Kch] {
+			continue
+		}
+		planned[course.Kch] = true
+		if plan.reason(course) != "" {
+			backups = append(backups, course.Kch)
+			continue
+		}
+		plan.commit(course)
+	}
+
+	if len(backups) > 0 {
+		fmt.Printf("⚠️  所选课程超出额度，以下课程仅作为候补: %s\n", strings.Join(backups, ", "))
+	}
+}
+
+// runCourseWorker keeps trying to register a course until it succeeds, gets a
+// terminal answer or ctx is cancelled, and reports whether it succeeded. Each
+// success answer is checked against the 已选课程 list before it is believed.
+func runCourseWorker(ctx context.Context, t *courseTarget, e *egress, burstUntil time.Time) bool {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	attempts := 0
+	minInterval, _ := courseInterval()
+	var lastRequest time.Time
+
+	if serverNow().Before(burstUntil) {
+		switch outcome := runBurst(ctx, t, e, burstUntil, &attempts); outcome {
+		case outcomeSuccess, outcomeSelected:
+			if done, held := settleClaim(ctx, t, e, outcome); done {
+				return held
+			}
+		case outcomeTerminal, outcomeStopped:
+			return false
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		logf("课程 %s 爆发阶段结束，恢复正常节奏\n", t.kch)
+	}
+
+	// Continue indefinitely until successful or manually stopped
+	for ctx.Err() == nil {
+		attempts++
+
+		// Respect the backoff of the host if it is throttling us, and hold
+		// while the workers are paused for new credentials
+		if !backoffs.wait(ctx, backoffKey(e, siteHost())) || !logins.wait(ctx) {
+			return false
+		}
+
+		// Keep the per-course minimum interval, then take a token from the
+		// global limiter shared by all courses
+		if !sleepContext(ctx, minInterval-time.Since(lastRequest)) {
+			return false
+		}
+		if !limiter.acquire(ctx, t.jx0404id, t.priority) {
+			return false
+		}
+		lastRequest = time.Now()
+
+		switch outcome := attemptSelection(ctx, t, e, attempts); outcome {
+		case outcomeSuccess, outcomeSelected:
+			if done, held := settleClaim(ctx, t, e, outcome); done {
+				return held
+			}
+		case outcomeTerminal, outcomeStopped:
+			return false
+		}
+	}
+	return false
+}
+
+// burstSlots caps the number of burst requests in flight across all courses
+var burstSlots chan struct{}
+var burstSlotsOnce sync.Once
+
+// runBurst keeps several requests of one course in flight until burstUntil,
+// stopping all of them as soon as one succeeds or gets a terminal answer.
+// It returns outcomeRetry if the burst phase ended without a result.
+func runBurst(ctx context.Context, t *courseTarget, e *egress, burstUntil time.Time, attempts *int) attemptOutcome {
+	burstSlotsOnce.Do(func() {
+		burstSlots = make(chan struct{}, burstMaxInFlight())
+	})
+
+	ctx, cancel := context.WithDeadline(ctx, burstUntil.Add(-serverOffset))
+	defer cancel()
+
+	var mu sync.Mutex
+	result := outcomeRetry
+	var wg sync.WaitGroup
+
+	for i := 0; i < burstParallel(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				// Hold while the workers are paused for new credentials,
+				// without keeping a slot other courses could use
+				if !logins.wait(ctx) {
+					return
+				}
+				select {
+				case burstSlots <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+
+				if !backoffs.wait(ctx, backoffKey(e, siteHost())) {
+					<-burstSlots
+					return
+				}
+				mu.Lock()
+				*attempts++
+				attempt := *attempts
+				mu.Unlock()
+
+				outcome := attemptSelection(ctx, t, e, attempt)
+				<-burstSlots
+
+				if outcome != outcomeRetry {
+					mu.Lock()
+					if result == outcomeRetry {
+						result = outcome
+					}
+					mu.Unlock()
+					cancel()
+					return
+				}
+				if ctx.Err() != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	return result
+}
+
+// prewarmConnections opens idle connections on every worker egress so that the
+// burst phase does not pay for TCP and TLS handshakes at the opening moment
+func prewarmConnections(courses int) {
+	perEgress := courses * burstParallel()
+	if perEgress > burstMaxInFlight() {
+		perEgress = burstMaxInFlight()
+	}
+
+	fmt.Printf("正在预热连接 (每个出口 %d 个)...\n", perEgress)
+
+	var wg sync.WaitGroup
+	for _, e := range workerEgresses {
+		for i := 0; i < perEgress; i++ {
+			wg.Add(1)
+			go func(e *egress) {
+				defer wg.Done()
+
+				req, err := http.NewRequest("GET", siteURL("/"), nil)
+				if err != nil {
+					return
+				}
+				req.Header.Set("Host", siteHost())
+
+				resp, err := e.loginClient.Do(req)
+				if err != nil {
+					return
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}(e)
+		}
+	}
+	wg.Wait()
+}
+
+// attemptSelection sends one selection request for a course and interprets the answer
+func attemptSelection(ctx context.Context, t *courseTarget, e *egress, attempt int) attemptOutcome {
+	kch := t.kch
+	if ctx.Err() != nil {
+		return outcomeRetry
+	}
+
+	// Get the latest cookies, logging in first if this egress has no session yet
+	localCookies := e.getCookies()
+	if len(localCookies) == 0 {
+		logf("课程 %s 通过 %s 建立会话...\n", kch, e.name)
+		if errors.Is(reloginFor(ctx, kch, e), errLoginRefused) {
+			return outcomeStopped
+		}
+		return outcomeRetry
+	}
+
+	url := siteURL(fmt.Sprintf("/xsxkkc/ggxxkxkOper?cfbs=null&jx0404id=%s&xkzy=&trjf=&_=%d",
+		t.currentSection().Jx0404id, serverNow().UnixMilli()))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		logf("课程 %s 请求创建失败: %v\n", kch, err)
+		return outcomeRetry
+	}
+
+	req.Header.Set("Host", siteHost())
+
+	// Add cookies to request
+	for _, cookie := range localCookies {
+		req.AddCookie(cookie)
+	}
+
+	stats.requests.Add(1)
+	sent := time.Now()
+	resp, err := e.client.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			stats.errors.Add(1)
+			logf("课程 %s 请求发送失败: %v\n", kch, err)
+			t.record(attempt, "请求发送失败", nil)
+		}
+		return outcomeRetry
+	}
+
+	e.mergeCookies(resp.Cookies())
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		if ctx.Err() == nil {
+			stats.errors.Add(1)
+			logf("课程 %s 响应读取失败: %v\n", kch, err)
+		}
+		return outcomeRetry
+	}
+	latency := time.Since(sent)
+
+	// Tell session expiry apart from WAF pages and HTTP errors, which
+	// need a slower pace instead of another login
+	responseStr := string(body)
+	key := backoffKey(e, req.URL.Host)
+
+	switch kind := classifyResponse(resp.StatusCode, body); {
+	case kind == respSessionExpired:
+		logf("课程 %s 会话已过期，准备重新登录...\n", kch)
+		t.observe(latency, false)
+		t.record(attempt, "会话已过期", body)
+		if errors.Is(reloginFor(ctx, kch, e), errLoginRefused) {
+			return outcomeStopped
+		}
+		return outcomeRetry
+	case kind.isThrottled():
+		delay := backoffs.failure(key, resp.Header.Get("Retry-After"))
+		stats.errors.Add(1)
+		t.observe(latency, false)
+		t.record(attempt, "限流: "+kind.String(), body)
+		logf("⚠️  课程 %s 正在被限流 (%s, HTTP %d)，%v 后重试\n",
+			kch, kind, resp.StatusCode, delay.Round(time.Millisecond))
+		return outcomeRetry
+	case kind == respUnexpected:
+		// Neither throttling nor a selection answer, so no backoff either
+		stats.errors.Add(1)
+		t.observe(latency, false)
+		t.record(attempt, "未知页面", body)
+		logf("⚠️  课程 %s 返回了未知页面 (HTTP %d)，继续重试\n", kch, resp.StatusCode)
+		return outcomeRetry
+	}
+	backoffs.success(key)
+
+	tracef("课程 %s 响应: %s\n", kch, responseStr)
+
+	// Parse the response
+	var result APIResponse
+
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		stats.errors.Add(1)
+		t.observe(latency, false)
+		t.record(attempt, "响应解析失败", body)
+		logf("课程 %s 响应解析失败: %v\n", kch, err)
+		return outcomeRetry
+	}
+	t.observe(latency, true)
+
+	// Check for success - handle different success message variations
+	successMsg := result.GetSuccessMessage()
+	if result.IsSuccess() && (strings.Contains(successMsg, "选课成功") ||
+		strings.Contains(successMsg, "success") ||
+		strings.Contains(successMsg, "成功")) {
+		t.record(attempt, successMsg, body)
+		return outcomeSuccess
+	}
+
+	t.record(attempt, successMsg, body)
+
+	// 已选 often answers a request sent just after a success, so it is
+	// checked against the 已选课程 list rather than taken as a refusal
+	if isSelectedMessage(successMsg) {
+		logf("课程 %s 尝试 %d: %s，核对已选课程\n", kch, attempt, successMsg)
+		return outcomeSelected
+	}
+	if isTerminalMessage(successMsg) {
+		logf("课程 %s 尝试 %d: %s，停止该课程\n", kch, attempt, successMsg)
+		return outcomeTerminal
+	}
+
+	tracef("课程 %s 尝试 %d: %s\n", kch, attempt, successMsg)
+	return outcomeRetry
+}
+
+// sleepContext sleeps for d and reports false if ctx was cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// reloginFor re-logs in through an egress on behalf of a course worker and
+// returns errLoginRefused if the user stopped instead of entering new
+// credentials
+func reloginFor(ctx context.Context, kch string, e *egress) error {
+	return reloginEgress(ctx, "课程 "+kch, kch, e)
+}
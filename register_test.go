@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSite is a selection server keeping the 已选课程 list of one student.
+// answer decides the reply to a selection request of a section that is not
+// held yet; a selected section is added to the list.
+type fakeSite struct {
+	mu       sync.Mutex
+	selected map[string]bool
+	dropped  []string
+	answer   func(id string, selected map[string]bool) (bool, string)
+}
+
+func (s *fakeSite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := r.URL.Query().Get("jx0404id")
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/xsxkkc/ggxxkxkOper"):
+		ok, message := s.answer(id, s.selected)
+		if s.selected[id] {
+			ok, message = false, "该课程已选"
+		} else if ok {
+			s.selected[id] = true
+		}
+		json.NewEncoder(w).Encode(map[string]any{"success": ok, "message": message})
+	case strings.HasSuffix(r.URL.Path, "/xsxkjg/comeXkjg"):
+		fmt.Fprint(w, "<html><body><table><tr><th>课程编号</th><th>操作</th></tr>")
+		for held := range s.selected {
+			fmt.Fprintf(w, `<tr><td>K%s</td><td><a onclick="xstkOper('%s')">退选</a></td></tr>`, held, held)
+		}
+		fmt.Fprint(w, "</table></body></html>")
+	case strings.HasSuffix(r.URL.Path, "/xsxkjg/xstkOper"):
+		delete(s.selected, id)
+		s.dropped = append(s.dropped, id)
+		fmt.Fprint(w, `{"success":true,"message":"退选成功"}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// useFakeSite points the profile and the egresses at site for one test
+func useFakeSite(t *testing.T, site *fakeSite) {
+	t.Helper()
+	server := httptest.NewServer(site)
+
+	savedProfile, savedPrimary, savedWorkers, savedLimiter := profile, primaryEgress, workerEgresses, limiter
+	t.Cleanup(func() {
+		server.Close()
+		profile, primaryEgress, workerEgresses, limiter = savedProfile, savedPrimary, savedWorkers, savedLimiter
+	})
+
+	profile = Profile{BaseURL: server.URL, CourseInterval: "10ms"}
+	e, err := newEgress("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	primaryEgress, workerEgresses = e, []*egress{e}
+	limiter = newRateLimiter(0)
+}
+
+// TestBundleMemberFailureDropsOthers selects one member of a bundle, lets the
+// other fail for good and checks that the first one is dropped again while a
+// course outside the bundle is kept
+func TestBundleMemberFailureDropsOthers(t *testing.T) {
+	site := &fakeSite{
+		selected: make(map[string]bool),
+		answer: func(id string, selected map[string]bool) (bool, string) {
+			switch id {
+			case "A", "C":
+				return true, "选课成功"
+			case "B":
+				// Refuse only once A is held, so the drop has something to undo
+				if selected["A"] {
+					return false, "选课失败: 上课时间冲突"
+				}
+				return false, "当前教学班人数已满"
+			}
+			return false, "未知教学班"
+		},
+	}
+	useFakeSite(t, site)
+
+	courses := []Course{
+		{Kch: "KA", Kcmc: "实验课", Jx0404id: "A"},
+		{Kch: "KB", Kcmc: "理论课", Jx0404id: "B"},
+		{Kch: "KC", Kcmc: "体育", Jx0404id: "C"},
+	}
+	cookies := []*http.Cookie{{Name: "JSESSIONID", Value: "test"}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	selected := registerForCourses(ctx, courses, [][]string{{"A", "B"}}, cookies, time.Time{})
+
+	if len(selected) != 1 || selected[0].Jx0404id != "C" {
+		t.Errorf("expected only C to stay selected, got %+v", selected)
+	}
+
+	site.mu.Lock()
+	defer site.mu.Unlock()
+	if site.selected["A"] || !site.selected["C"] {
+		t.Errorf("server still holds %v", site.selected)
+	}
+	if len(site.dropped) != 1 || site.dropped[0] != "A" {
+		t.Errorf("expected A to be dropped once, dropped %v", site.dropped)
+	}
+
+	states := make(map[string]string)
+	for _, target := range lastRun {
+		states[target.jx0404id] = target.snapshot().state
+	}
+	want := map[string]string{"A": "已退选", "B": "失败", "C": "成功"}
+	for id, state := range want {
+		if states[id] != state {
+			t.Errorf("%s: state %s, want %s", id, states[id], state)
+		}
+	}
+}
+
+// TestAlreadySelectedAnswerIsConfirmed checks that an 已选 answer for a
+// section on the 已选课程 list counts as held rather than failed
+func TestAlreadySelectedAnswerIsConfirmed(t *testing.T) {
+	site := &fakeSite{
+		selected: make(map[string]bool),
+		answer: func(id string, selected map[string]bool) (bool, string) {
+			return false, "当前教学班人数已满"
+		},
+	}
+	useFakeSite(t, site)
+
+	// Held after the run started, as when the first success answer was lost
+	target := &courseTarget{kch: "KA", jx0404id: "A", priority: 1, section: Course{Kch: "KA", Jx0404id: "A"}}
+	primaryEgress.setCookies([]*http.Cookie{{Name: "JSESSIONID", Value: "test"}})
+	site.mu.Lock()
+	site.selected["A"] = true
+	site.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if !runCourseWorker(ctx, target, primaryEgress, time.Time{}) {
+		t.Fatal("a section the list shows should end as selected")
+	}
+	if !target.snapshot().confirmed {
+		t.Error("the section should be confirmed")
+	}
+}
+
+// TestUnbackedSelectedAnswerRetries checks that an 已选 answer for a section
+// missing from the 已选课程 list sends the worker back to retrying
+func TestUnbackedSelectedAnswerRetries(t *testing.T) {
+	answers := 0
+	site := &fakeSite{
+		selected: make(map[string]bool),
+		answer: func(id string, selected map[string]bool) (bool, string) {
+			answers++
+			if answers == 1 {
+				return false, "该课程已选"
+			}
+			return true, "选课成功"
+		},
+	}
+	useFakeSite(t, site)
+
+	target := &courseTarget{kch: "KA", jx0404id: "A", priority: 1, section: Course{Kch: "KA", Jx0404id: "A"}}
+	primaryEgress.setCookies([]*http.Cookie{{Name: "JSESSIONID", Value: "test"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if !runCourseWorker(ctx, target, primaryEgress, time.Time{}) {
+		t.Fatal("the worker should retry and select the section")
+	}
+	if answers < 2 || !target.snapshot().confirmed {
+		t.Errorf("expected a confirmed selection after a retry, got %d answers", answers)
+	}
+}
+
+// TestFullClassAnswersKeepRetrying checks that the answers of a full class
+// neither stop the worker nor count as held
+func TestFullClassAnswersKeepRetrying(t *testing.T) {
+	full := []string{"选课人数已达上限", "当前教学班已选满", "已选人数已达上限", "当前教学班人数已满"}
+	answers := 0
+	site := &fakeSite{
+		selected: make(map[string]bool),
+		answer: func(id string, selected map[string]bool) (bool, string) {
+			answers++
+			if answers <= len(full) {
+				return false, full[answers-1]
+			}
+			return true, "选课成功"
+		},
+	}
+	useFakeSite(t, site)
+
+	target := &courseTarget{kch: "KA", jx0404id: "A", priority: 1, section: Course{Kch: "KA", Jx0404id: "A"}}
+	primaryEgress.setCookies([]*http.Cookie{{Name: "JSESSIONID", Value: "test"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if !runCourseWorker(ctx, target, primaryEgress, time.Time{}) {
+		t.Fatalf("the worker stopped after %d answers", answers)
+	}
+	if answers != len(full)+1 {
+		t.Errorf("expected %d answers, got %d", len(full)+1, answers)
+	}
+}
+
+// TestSelectionMessages checks which refusals are final and which mean the
+// section is already held
+func TestSelectionMessages(t *testing.T) {
+	tests := []struct {
+		msg      string
+		terminal bool
+		selected bool
+	}{
+		{"选课人数已达上限", false, false},
+		{"当前教学班已选满", false, false},
+		{"已选人数已达上限", false, false},
+		{"学分已达上限，不能再选", true, false},
+		{"选课门数已达上限", true, false},
+		{"上课时间冲突", true, false},
+		{"该课程已选", false, true},
+		{"您已经选过该课程", false, true},
+		{"不能重复选课", false, true},
+	}
+	for _, tt := range tests {
+		if got := isTerminalMessage(tt.msg); got != tt.terminal {
+			t.Errorf("%s: terminal %v, want %v", tt.msg, got, tt.terminal)
+		}
+		if got := isSelectedMessage(tt.msg); got != tt.selected {
+			t.Errorf("%s: selected %v, want %v", tt.msg, got, tt.selected)
+		}
+	}
+}
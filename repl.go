@@ -0,0 +1,721 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shellHelp lists the commands of the interactive shell with their usage
+var shellHelp = [][2]string{
+	{"sessions", "列出可用的选课会话"},
+	{"use <序号>", "进入选课会话并加载课程列表"},
+	{"wait [名称或学期]", "等待匹配的选课会话开放后自动进入"},
+	{"list [关键字...]", "列出课程，可按课程号、名称、教师、类别或时间筛选"},
+	{"show <课程号>", "查看课程的详细信息"},
+	{"add <课程号|选课ID>...", "加入选课篮，先加入的优先级更高"},
+	{"remove <课程号|选课ID>", "从选课篮中移除"},
+	{"basket", "查看选课篮"},
+	{"bundle [课程号|选课ID...]", "捆绑多个教学班，一个选不上时退选其余已选上的；不带参数列出捆绑"},
+	{"conflicts", "检查选课篮中的时间冲突"},
+	{"plan <类别=学分[:门数],...>", "按通选课类别要求规划不冲突的课程组合"},
+	{"go", "开始抢选课篮中的课程，按 Ctrl+C 停止"},
+	{"status", "查看上一次选课的状态"},
+	{"selected", "查看已选课程"},
+	{"drop <课程号|选课ID>", "退选已选课程"},
+	{"swap <已选> <目标> [时长]", "目标有空位时退选已选课程并抢选目标，失败则立即选回"},
+	{"monitor [间隔]", "定时保存课程列表快照，提示新教学班、余量、教师和地点的变化"},
+	{"report [关键字...]", "根据 monitor 记录的余量统计名额释放时段、满员速度和选课比例"},
+	{"help", "显示帮助"},
+	{"quit", "退出"},
+}
+
+// Shell state kept between commands
+var (
+	sessionList    []CourseSession
+	basket         []Course   // Sections to register for, highest priority first
+	bundles        [][]string // Groups of basket jx0404ids selected all or nothing
+	selectedCache  []selectedCourse
+	shellStartTime time.Time
+)
+
+// runShell reads and runs commands until the user quits
+func runShell(start time.Time) {
+	shellStartTime = start
+	console.complete = completeShell
+
+	fmt.Println("\n输入 help 查看可用命令，Tab 键补全命令和课程号")
+	if profile.SessionFilter != "" {
+		waitAndEnterSession(profile.SessionFilter)
+	} else {
+		listSessions()
+	}
+
+	for {
+		line, err := console.readCommand("xk> ")
+		if errors.Is(err, errInterrupted) {
+			fmt.Println("输入 quit 退出")
+			continue
+		}
+		if err == io.EOF {
+			return
+		}
+
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
+		}
+		if words[0] == "quit" || words[0] == "exit" {
+			return
+		}
+		runShellCommand(words[0], words[1:])
+	}
+}
+
+// runShellCommand dispatches one command
+func runShellCommand(name string, args []string) {
+	switch name {
+	case "help", "?":
+		for _, entry := range shellHelp {
+			fmt.Printf("  %s%s %s\n", entry[0], strings.Repeat(" ", max(0, 28-displayWidth(entry[0]))), entry[1])
+		}
+	case "sessions":
+		listSessions()
+	case "use":
+		if len(args) != 1 {
+			fmt.Println("用法: use <序号>")
+			return
+		}
+		useSession(args[0])
+	case "wait":
+		waitAndEnterSession(strings.Join(args, " "))
+	case "list":
+		if requireCatalog() {
+			listCourses(args)
+		}
+	case "show":
+		if len(args) != 1 {
+			fmt.Println("用法: show <课程号>")
+			return
+		}
+		if !requireCatalog() {
+			return
+		}
+		matches := findCourses(args[0])
+		if len(matches) == 0 {
+			fmt.Printf("课程号 %s 不存在\n", args[0])
+		}
+		for _, c := range matches {
+			printCourseDetail(c)
+		}
+	case "add":
+		if len(args) == 0 {
+			fmt.Println("用法: add <课程号|选课ID>...")
+			return
+		}
+		if requireCatalog() {
+			for _, id := range args {
+				addToBasket(id)
+			}
+		}
+	case "remove", "rm":
+		if len(args) != 1 {
+			fmt.Println("用法: remove <课程号|选课ID>")
+			return
+		}
+		removeFromBasket(args[0])
+	case "basket":
+		printBasket()
+	case "bundle":
+		switch {
+		case len(args) == 0:
+			printBundles()
+		case len(args) == 1:
+			fmt.Println("用法: bundle <课程号|选课ID> <课程号|选课ID>...")
+		case requireCatalog():
+			addBundle(args)
+		}
+	case "conflicts":
+		printBasketConflicts()
+	case "plan":
+		if len(args) == 0 {
+			fmt.Println("用法: plan <类别=学分[:门数],...>")
+			return
+		}
+		if requireCatalog() {
+			for _, c := range planCourses(strings.Join(args, ""), basket) {
+				addSection(c)
+			}
+		}
+	case "go":
+		if requireCatalog() {
+			runBasket()
+		}
+	case "status":
+		printRunStatus()
+	case "selected":
+		if requireSession() {
+			listSelectedCourses()
+		}
+	case "drop":
+		if len(args) != 1 {
+			fmt.Println("用法: drop <课程号|选课ID>")
+			return
+		}
+		if requireSession() {
+			dropSelectedCourse(args[0])
+		}
+	case "swap":
+		if requireCatalog() {
+			runSwap(args)
+		}
+	case "monitor":
+		if requireSession() {
+			runMonitor(args)
+		}
+	case "report":
+		runReport(args)
+	default:
+		fmt.Printf("未知命令 %s，输入 help 查看可用命令\n", name)
+	}
+}
+
+// completeShell returns completion candidates for the last word of a command line
+func completeShell(words []string) []string {
+	if len(words) <= 1 {
+		var names []string
+		for _, entry := range shellHelp {
+			names = append(names, strings.Fields(entry[0])[0])
+		}
+		return names
+	}
+
+	seen := make(map[string]bool)
+	var codes []string
+	addCode := func(code string) {
+		if code != "" && !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+
+	switch words[0] {
+	case "show", "add", "bundle":
+		for _, c := range courseCatalog {
+			addCode(c.Kch)
+		}
+	case "remove", "rm":
+		for _, c := range basket {
+			addCode(c.Kch)
+		}
+	case "drop":
+		for _, c := range selectedCache {
+			addCode(c.Kch)
+		}
+	case "swap":
+		if len(words) == 2 {
+			for _, c := range selectedCache {
+				addCode(c.Kch)
+			}
+		} else {
+			for _, c := range courseCatalog {
+				addCode(c.Kch)
+			}
+		}
+	case "use":
+		for i := range sessionList {
+			addCode(strconv.Itoa(i + 1))
+		}
+	}
+	return codes
+}
+
+// requireSession reports whether a session has been entered, telling the user otherwise
+func requireSession() bool {
+	if selectedSession.URL == "" {
+		fmt.Println("请先使用 use <序号> 进入选课会话")
+		return false
+	}
+	return true
+}
+
+// requireCatalog reports whether the course list is loaded, telling the user otherwise
+func requireCatalog() bool {
+	if !requireSession() {
+		return false
+	}
+	if len(courseCatalog) == 0 {
+		fmt.Println("课程列表为空，请重新执行 use 加载")
+		return false
+	}
+	return true
+}
+
+// listSessions fetches and prints the available course selection sessions
+func listSessions() {
+	sessions, err := getSessionList(primaryEgress.getCookies())
+	if err != nil {
+		fmt.Printf("获取选课会话失败: %v\n", err)
+		return
+	}
+	sessionList = sessions
+
+	var list []map[string]any
+	for i, session := range sessions {
+		list = append(list, map[string]any{
+			"index":     i + 1,
+			"term":      session.Term,
+			"name":      session.Name,
+			"time":      session.Time,
+			"status":    session.Status,
+			"entry_url": session.EntryURL,
+			"view_url":  session.ViewURL,
+			"url":       session.URL,
+		})
+	}
+	emitEvent("sessions", map[string]any{"sessions": list})
+
+	fmt.Println("\n可用的选课会话:")
+	tbl := newTable(
+		tableColumn{title: "序号", min: 4},
+		tableColumn{title: "学年学期", min: 8},
+		tableColumn{title: "选课名称", min: 8, wrap: true},
+		tableColumn{title: "选课时间", min: 10, wrap: true},
+		tableColumn{title: "状态", min: 6, color: sessionStatusColor},
+	)
+	for i, session := range sessions {
+		tbl.addRow(strconv.Itoa(i+1), session.Term, session.Name, session.Time, session.Status)
+	}
+	tbl.render()
+}
+
+// useSession enters a session by its number
+func useSession(arg string) {
+	index, err := strconv.Atoi(arg)
+	if err != nil || index < 1 || index > len(sessionList) {
+		fmt.Printf("无效的选择，请输入 1-%d 之间的数字\n", len(sessionList))
+		return
+	}
+	enterSession(sessionList[index-1])
+}
+
+// enterSession authenticates with a session and loads its course list. The
+// courses and bundles of the profile are added to an empty basket.
+func enterSession(session CourseSession) bool {
+	if session.URL != selectedSession.URL {
+		basket = nil
+		bundles = nil
+		selectedCache = nil
+	}
+	selectedSession = session
+	fmt.Printf("\n已选择: %s - %s\n", selectedSession.Term, selectedSession.Name)
+
+	if err := refreshAuthentication(primaryEgress, primaryEgress.getCookies()); err != nil {
+		fmt.Printf("认证失败: %v\n", err)
+		return false
+	}
+
+	courses, err := loadCourseList(primaryEgress.getCookies())
+	if err != nil {
+		fmt.Printf("获取课程列表失败: %v\n", err)
+		return false
+	}
+	emitEvent("courses", map[string]any{"session": selectedSession.Name, "term": selectedSession.Term, "courses": courses})
+	fmt.Printf("已加载 %d 个课程，输入 list 查看\n", len(courses))
+
+	if len(basket) == 0 {
+		for _, id := range profile.Courses {
+			if id = strings.TrimSpace(id); id != "" {
+				addToBasket(id)
+			}
+		}
+		for _, ids := range profile.Bundles {
+			addBundle(ids)
+		}
+	}
+	return true
+}
+
+// waitAndEnterSession waits for a matching session to open, enters it and,
+// if the profile asks for it, starts registering the basket right away.
+// Ctrl+C stops waiting.
+func waitAndEnterSession(filter string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	session, err := waitForSession(ctx, filter)
+	stop()
+	if err != nil {
+		fmt.Printf("\n已停止等待: %v\n", err)
+		return
+	}
+
+	if !enterSession(session) {
+		return
+	}
+	if profile.AutoGo && len(basket) > 0 {
+		runBasket()
+	}
+}
+
+// listCourses prints the courses matching every filter word
+func listCourses(filters []string) {
+	var matches []Course
+	for _, c := range courseCatalog {
+		if courseMatches(c, filters) {
+			matches = append(matches, c)
+		}
+	}
+
+	fmt.Println()
+	printCourseTable(matches)
+	fmt.Printf("共 %d 个课程\n", len(matches))
+}
+
+// courseMatches reports whether a section contains every filter word in its
+// code, name, teacher, category, time or jx0404id, ignoring case
+func courseMatches(c Course, filters []string) bool {
+	text := strings.ToLower(strings.Join([]string{c.Kch, c.Kcmc, c.Skls, c.Szkcflmc, c.Sksj, c.Jx0404id}, " "))
+	for _, f := range filters {
+		if !strings.Contains(text, strings.ToLower(f)) {
+			return false
+		}
+	}
+	return true
+}
+
+// addToBasket adds the section identified by a course code or jx0404id
+func addToBasket(id string) {
+	if c, ok := pickSection(id); ok {
+		addSection(c)
+	}
+}
+
+// pickSection finds the single section identified by a course code or
+// jx0404id, listing the sections if a course code has several
+func pickSection(id string) (Course, bool) {
+	matches := findCourses(id)
+	switch len(matches) {
+	case 0:
+		fmt.Printf("课程号 %s 不存在\n", id)
+	case 1:
+		return matches[0], true
+	default:
+		fmt.Printf("课程 %s 有 %d 个教学班，请用选课ID指定:\n", id, len(matches))
+		for _, c := range matches {
+			fmt.Printf("  %s  %s %s 剩余 %s\n", c.Jx0404id, c.Skls, c.Sksj, c.Syrs)
+		}
+	}
+	return Course{}, false
+}
+
+// addBundle adds sections to the basket as a bundle: if one of them cannot be
+// selected, the others selected in the same run are dropped again
+func addBundle(ids []string) {
+	var members []Course
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		c, ok := pickSection(strings.TrimSpace(id))
+		if !ok {
+			return
+		}
+		if !seen[c.Jx0404id] {
+			seen[c.Jx0404id] = true
+			members = append(members, c)
+		}
+	}
+	if len(members) < 2 {
+		fmt.Println("捆绑至少需要两个不同的教学班")
+		return
+	}
+	for _, b := range bundles {
+		for _, id := range b {
+			if seen[id] {
+				fmt.Printf("选课ID %s 已经在其他捆绑中了\n", id)
+				return
+			}
+		}
+	}
+
+	var group []string
+	for _, c := range members {
+		addSection(c)
+		group = append(group, c.Jx0404id)
+	}
+	bundles = append(bundles, group)
+	fmt.Printf("已捆绑 %d 个教学班，其中一个选不上时会退选其余已选上的\n", len(group))
+	emitEvent("bundle_added", map[string]any{"members": group})
+}
+
+// printBundles lists the bundles of the basket
+func printBundles() {
+	if len(bundles) == 0 {
+		fmt.Println("没有捆绑的课程，使用 bundle <课程号> <课程号>... 添加")
+		return
+	}
+
+	names := make(map[string]string)
+	for _, c := range basket {
+		names[c.Jx0404id] = c.Kch + " " + c.Kcmc
+	}
+	fmt.Println("捆绑的课程 (全部选上或一个都不保留):")
+	for i, b := range bundles {
+		var parts []string
+		for _, id := range b {
+			parts = append(parts, names[id]+" ("+id+")")
+		}
+		fmt.Printf("  %d. %s\n", i+1, strings.Join(parts, " + "))
+	}
+}
+
+// pruneBundles dissolves the bundles that lost a member from the basket
+func pruneBundles() {
+	inBasket := make(map[string]bool)
+	for _, c := range basket {
+		inBasket[c.Jx0404id] = true
+	}
+
+	kept := bundles[:0]
+	for _, b := range bundles {
+		left := 0
+		for _, id := range b {
+			if inBasket[id] {
+				left++
+			}
+		}
+		if left == len(b) {
+			kept = append(kept, b)
+			continue
+		}
+		if left > 0 {
+			fmt.Printf("捆绑 %s 缺少成员，已解除捆绑\n", strings.Join(b, " + "))
+		}
+	}
+	bundles = kept
+}
+
+// addSection appends a section to the basket unless it is already there
+func addSection(c Course) {
+	for _, b := range basket {
+		if b.Jx0404id == c.Jx0404id {
+			fmt.Printf("课程 %s 已经在选课篮中了\n", c.Kch)
+			return
+		}
+	}
+	basket = append(basket, c)
+	fmt.Printf("已添加课程: %s %s\n", c.Kch, c.Kcmc)
+	emitEvent("selection_added", map[string]any{"kch": c.Kch, "jx0404id": c.Jx0404id, "name": c.Kcmc, "priority": len(basket)})
+	printCourseWarnings(c)
+}
+
+// removeFromBasket removes the sections matching a course code or jx0404id
+func removeFromBasket(id string) {
+	kept := basket[:0]
+	removed := 0
+	for _, c := range basket {
+		if c.Jx0404id == id || c.Kch == id {
+			removed++
+			emitEvent("selection_removed", map[string]any{"kch": c.Kch, "jx0404id": c.Jx0404id, "name": c.Kcmc})
+			continue
+		}
+		kept = append(kept, c)
+	}
+	basket = kept
+	pruneBundles()
+
+	if removed == 0 {
+		fmt.Printf("选课篮中没有 %s\n", id)
+		return
+	}
+	fmt.Printf("已移除 %d 个课程\n", removed)
+}
+
+// printBasket lists the basket in priority order with the total credits
+func printBasket() {
+	if len(basket) == 0 {
+		fmt.Println("选课篮为空，使用 add <课程号> 添加")
+		return
+	}
+
+	var credits float64
+	tbl := newTable(
+		tableColumn{title: "优先", min: 4},
+		tableColumn{title: "课程编号", min: 8},
+		tableColumn{title: "选课ID", min: 8},
+		tableColumn{title: "课程名称", min: 8, max: 24, wrap: true},
+		tableColumn{title: "学分", min: 4},
+		tableColumn{title: "上课时间", min: 10, max: 24, wrap: true},
+		tableColumn{title: "剩余量", min: 6, color: seatsColor},
+	)
+	for i, c := range basket {
+		remaining := c.Syrs.String()
+		if c.Syrs == 0 {
+			remaining = "满"
+		}
+		tbl.addRow(strconv.Itoa(i+1), c.Kch, c.Jx0404id, c.Kcmc, c.Xf.String(), c.Sksj, remaining)
+		credits += float64(c.Xf)
+	}
+	tbl.render()
+	fmt.Printf("共 %d 门，%s 学分\n", len(basket), flexFloat(credits))
+	if len(bundles) > 0 {
+		printBundles()
+	}
+
+	if budget := newCreditBudget(); budget.enabled() {
+		fmt.Printf("选课额度限制: %s\n", budget.summary())
+		checkBudgetPlan(basket)
+	}
+}
+
+// printBasketConflicts reports every pair of basket courses that meet at the same time
+func printBasketConflicts() {
+	found := false
+	for i := range basket {
+		for j := i + 1; j < len(basket); j++ {
+			if coursesConflict(basket[i], basket[j]) {
+				found = true
+				fmt.Printf("⚠️  %s %s 与 %s %s 时间冲突\n", basket[i].Kch, basket[i].Kcmc, basket[j].Kch, basket[j].Kcmc)
+			}
+		}
+	}
+	if !found {
+		fmt.Println("选课篮中的课程没有时间冲突")
+	}
+}
+
+// runBasket registers for the basket in the foreground. Ctrl+C stops the run
+// and returns to the shell; selected courses are removed from the basket.
+func runBasket() {
+	if len(basket) == 0 {
+		fmt.Println("选课篮为空，使用 add <课程号> 添加")
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	syncServerClock(primaryEgress)
+	start := shellStartTime
+	if !start.IsZero() && serverNow().Before(start) {
+		// Open the burst connections shortly before the start so they are still idle-alive
+		if d, _ := burstDuration(); d > 0 {
+			if waitUntilServerTime(ctx, start.Add(-3*time.Second)) != nil {
+				fmt.Println("\n已取消")
+				return
+			}
+			prewarmConnections(len(basket))
+		}
+		if waitUntilServerTime(ctx, start) != nil {
+			fmt.Println("\n已取消")
+			return
+		}
+	}
+
+	fmt.Println("\n开始选课，按 Ctrl+C 停止并返回命令行...")
+	selected := registerForCourses(ctx, basket, bundles, primaryEgress.getCookies(), start)
+	// Every section of a selected or held course code leaves the basket
+	for _, c := range selected {
+		removeFromBasket(c.Kch)
+	}
+	for _, t := range lastRun {
+		if t.snapshot().state == "已持有" {
+			removeFromBasket(t.kch)
+		}
+	}
+	if ctx.Err() != nil {
+		fmt.Println("选课已停止，输入 status 查看各课程状态")
+	}
+}
+
+// printRunStatus shows the state of every target of the latest run
+func printRunStatus() {
+	if len(lastRun) == 0 {
+		fmt.Println("还没有运行过选课")
+		return
+	}
+
+	tbl := newTable(
+		tableColumn{title: "课程编号", min: 8},
+		tableColumn{title: "课程名称", min: 8, max: 24},
+		tableColumn{title: "状态", min: 6},
+		tableColumn{title: "尝试", min: 4},
+		tableColumn{title: "最后响应", min: 10, wrap: true},
+	)
+	for _, t := range lastRun {
+		st := t.snapshot()
+		tbl.addRow(t.kch, t.name, st.state, strconv.Itoa(st.attempts), st.lastMessage)
+	}
+	tbl.render()
+}
+
+// listSelectedCourses fetches and prints the courses already selected
+func listSelectedCourses() {
+	courses, err := fetchSelectedCourses(primaryEgress)
+	if err != nil {
+		fmt.Printf("获取已选课程失败: %v\n", err)
+		return
+	}
+	selectedCache = courses
+
+	if len(courses) == 0 {
+		fmt.Println("还没有已选课程")
+		return
+	}
+
+	tbl := newTable(
+		tableColumn{title: "课程编号", min: 8},
+		tableColumn{title: "选课ID", min: 8},
+		tableColumn{title: "课程名称", min: 8, max: 24, wrap: true},
+		tableColumn{title: "学分", min: 4},
+		tableColumn{title: "教师", min: 6, max: 12},
+		tableColumn{title: "上课时间", min: 10, max: 24, wrap: true},
+		tableColumn{title: "上课地点", min: 8, max: 20, wrap: true},
+	)
+	for _, c := range courses {
+		tbl.addRow(c.Kch, c.Jx0404id, c.Kcmc, c.Xf, c.Skls, c.Sksj, c.Skdd)
+	}
+	tbl.render()
+}
+
+// dropSelectedCourse withdraws a selected course after asking for confirmation
+func dropSelectedCourse(id string) {
+	if len(selectedCache) == 0 {
+		courses, err := fetchSelectedCourses(primaryEgress)
+		if err != nil {
+			fmt.Printf("获取已选课程失败: %v\n", err)
+			return
+		}
+		selectedCache = courses
+	}
+
+	var target *selectedCourse
+	for i, c := range selectedCache {
+		if c.Jx0404id == id || c.Kch == id {
+			target = &selectedCache[i]
+			break
+		}
+	}
+	if target == nil {
+		fmt.Printf("已选课程中没有 %s，输入 selected 刷新\n", id)
+		return
+	}
+
+	answer := readInput(fmt.Sprintf("确定退选 %s %s 吗? (y/N): ", target.Kch, target.Kcmc))
+	if !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+		fmt.Println("已取消")
+		return
+	}
+
+	err := dropCourse(primaryEgress, target.Jx0404id)
+	emitEvent("drop", map[string]any{"kch": target.Kch, "jx0404id": target.Jx0404id, "ok": err == nil, "error": errorText(err)})
+	if err != nil {
+		fmt.Printf("退选失败: %v\n", err)
+		return
+	}
+	fmt.Printf("已退选 %s %s\n", target.Kch, target.Kcmc)
+	selectedCache = nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// selectedCourse is one row of the 已选课程 page
+type selectedCourse struct {
+	Kch      string // 课程编号
+	Kcmc     string // 课程名称
+	Xf       string // 学分
+	Skls     string // 上课老师
+	Sksj     string // 上课时间
+	Skdd     string // 上课地点
+	Jx0404id string // 选课ID, needed to drop the course
+}
+
+// Extracts the jx0404id from a 退选 link such as javascript:xstkOper('...')
+var selectedIDPattern = regexp.MustCompile(`(?:xstkOper\(\s*['"]|jx0404id=)([0-9A-Za-z]+)`)
+
+// fetchSelectedCourses loads the courses the student has already selected in the current session
+func fetchSelectedCourses(e *egress) ([]selectedCourse, error) {
+	body, err := getSessionPage(e, siteURL("/xsxkjg/comeXkjg"))
+	if err != nil {
+		return nil, err
+	}
+	return parseSelectedCourses(string(body))
+}
+
+// heldSections returns the courses among targets that the student already
+// holds, keyed by jx0404id, with the held section of the same course code.
+// Holding any section of a course satisfies every target of that code.
+func heldSections(e *egress, targets []Course) (map[string]selectedCourse, error) {
+	selected, err := fetchSelectedCourses(e)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]selectedCourse)
+	byKch := make(map[string]selectedCourse)
+	for _, c := range selected {
+		byID[c.Jx0404id] = c
+		if c.Kch != "" {
+			byKch[c.Kch] = c
+		}
+	}
+
+	held := make(map[string]selectedCourse)
+	for _, t := range targets {
+		if c, ok := byID[t.Jx0404id]; ok {
+			held[t.Jx0404id] = c
+		} else if c, ok := byKch[t.Kch]; ok {
+			held[t.Jx0404id] = c
+		}
+	}
+	return held, nil
+}
+
+// parseSelectedCourses extracts the rows of the 已选课程 table. Columns are
+// located by their header text because schools order them differently. A
+// page with neither a course table header nor a 退选 link is an error, so
+// that an error page is not mistaken for an empty list.
+func parseSelectedCourses(page string) ([]selectedCourse, error) {
+	var courses []selectedCourse
+	recognised := false
+	for _, table := range extractTables(tokenizeHTML(page)) {
+		columns := make(map[string]int)
+		for _, row := range table.Rows {
+			text := func(field string) string {
+				if i, ok := columns[field]; ok && i < len(row.Cells) {
+					return row.Cells[i].Text
+				}
+				return ""
+			}
+
+			if len(row.Cells) > 0 && row.Cells[0].Header {
+				for i, cell := range row.Cells {
+					switch header := cell.Text; {
+					case strings.Contains(header, "课程编号") || strings.Contains(header, "课程号"):
+						columns["kch"] = i
+						recognised = true
+					case strings.Contains(header, "课程名称"):
+						columns["kcmc"] = i
+						recognised = true
+					case strings.Contains(header, "学分"):
+						columns["xf"] = i
+					case strings.Contains(header, "老师") || strings.Contains(header, "教师"):
+						columns["skls"] = i
+					case strings.Contains(header, "时间"):
+						columns["sksj"] = i
+					case strings.Contains(header, "地点"):
+						columns["skdd"] = i
+					}
+				}
+				continue
+			}
+
+			id := ""
+			for _, cell := range row.Cells {
+				for _, link := range cell.Links {
+					if m := selectedIDPattern.FindStringSubmatch(link.Href + " " + link.Onclick); m != nil {
+						id = m[1]
+					}
+				}
+			}
+			if id == "" {
+				continue
+			}
+			recognised = true
+			courses = append(courses, selectedCourse{
+				Kch:      text("kch"),
+				Kcmc:     text("kcmc"),
+				Xf:       text("xf"),
+				Skls:     text("skls"),
+				Sksj:     text("sksj"),
+				Skdd:     text("skdd"),
+				Jx0404id: id,
+			})
+		}
+	}
+	if !recognised {
+		return nil, fmt.Errorf("无法识别已选课程页面")
+	}
+	return courses, nil
+}
+
+// dropCourse withdraws a selected course
+func dropCourse(e *egress, jx0404id string) error {
+	url := siteURL(fmt.Sprintf("/xsxkjg/xstkOper?jx0404id=%s&_=%d",
+		jx0404id, serverNow().UnixMilli()))
+	body, err := getSessionPage(e, url)
+	if err != nil {
+		return err
+	}
+
+	var result APIResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("退选响应解析失败: %v", err)
+	}
+	if !result.IsSuccess() {
+		return fmt.Errorf("%s", result.GetSuccessMessage())
+	}
+	return nil
+}
+
+// getSessionPage sends an authenticated GET request and checks that the
+// session is still valid and the server is not throttling us. Pages other
+// than the login and WAF pages are returned as they are.
+func getSessionPage(e *egress, url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Host", siteHost())
+	for _, cookie := range e.getCookies() {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	e.mergeCookies(resp.Cookies())
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind := classifyResponse(resp.StatusCode, body); {
+	case kind == respSessionExpired:
+		return nil, fmt.Errorf("会话已过期，请重新进入选课会话")
+	case kind.isThrottled():
+		delay := backoffs.failure(backoffKey(e, req.URL.Host), resp.Header.Get("Retry-After"))
+		return nil, &throttledError{kind: kind, delay: delay}
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("请求失败，状态码: %d", resp.StatusCode)
+	}
+	return body, nil
+}
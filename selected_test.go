@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseSelectedCourses parses every testdata/selected_*.html fixture and
+// compares the courses with the matching .golden file
+func TestParseSelectedCourses(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "selected_*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures found")
+	}
+
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".html")
+		t.Run(name, func(t *testing.T) {
+			page, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			courses, err := parseSelectedCourses(string(page))
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareGolden(t, fixture, courses)
+		})
+	}
+}
+
+// TestParseSelectedCoursesUnrecognised checks that pages without a course
+// table are errors rather than an empty list
+func TestParseSelectedCoursesUnrecognised(t *testing.T) {
+	pages := []string{
+		``,
+		`<html><body>系统繁忙，请稍后再试</body></html>`,
+		`<html><body><table><tr><th>通知</th></tr><tr><td>选课未开始</td></tr></table></body></html>`,
+	}
+	for _, page := range pages {
+		if courses, err := parseSelectedCourses(page); err == nil {
+			t.Errorf("%q: expected an error, got %+v", page, courses)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// errSessionExpired is returned when the server answers with the login page
+var errSessionExpired = errors.New("会话已过期，请重新登录")
+
+// errNoSessions is returned when the session list has no sessions yet
+var errNoSessions = errors.New("无法从响应中提取选课会话信息")
+
+var (
+	// Date with optional time of day, as used in the 选课时间 column
+	sessionTimePattern = regexp.MustCompile(`\d{4}-\d{1,2}-\d{1,2}(?:[ T]+\d{1,2}:\d{2}(?::\d{2})?)?`)
+	// Quoted path inside a javascript: link or onclick handler
+	quotedPathPattern = regexp.MustCompile(`['"]([^'"]*(?:xsxk|xklc)[^'"]*)['"]`)
+)
+
+// getSessionList fetches the list of available course selection sessions
+func getSessionList(cookies []*http.Cookie) ([]CourseSession, error) {
+	req, err := http.NewRequest("GET", siteURL("/xsxk/xklc_list"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Host", siteHost())
+
+	// Add cookies to request
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := primaryEgress.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Read the response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// The list is a page, so any other page is what we asked for
+	switch kind := classifyResponse(resp.StatusCode, body); {
+	case kind == respSessionExpired:
+		return nil, errSessionExpired
+	case kind.isThrottled():
+		delay := backoffs.failure(backoffKey(primaryEgress, req.URL.Host), resp.Header.Get("Retry-After"))
+		return nil, &throttledError{kind: kind, delay: delay}
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get session list with status code: %d", resp.StatusCode)
+	}
+
+	return parseSessionList(string(body), serverNow())
+}
+
+// parseSessionList extracts the sessions of the xklc_list page. Columns are
+// found by their header text; sessions without a status column get one
+// derived from their time range and now.
+func parseSessionList(page string, now time.Time) ([]CourseSession, error) {
+	tokens := tokenizeHTML(page)
+
+	var sessions []CourseSession
+	seen := make(map[string]bool)
+	add := func(s CourseSession) {
+		if s.URL == "" || seen[s.URL] {
+			return
+		}
+		seen[s.URL] = true
+		if s.Status == "" {
+			s.Status = sessionStatus(s.Time, now)
+		}
+		sessions = append(sessions, s)
+	}
+
+	for _, table := range extractTables(tokens) {
+		for _, s := range sessionsFromTable(table) {
+			add(s)
+		}
+	}
+
+	// Some pages put the links outside any recognisable table
+	if len(sessions) == 0 {
+		for _, link := range extractLinks(tokens) {
+			target := linkTarget(link)
+			if !strings.Contains(target, "xsxk") && !strings.Contains(target, "xklc") {
+				continue
+			}
+			if !strings.Contains(link.Text, "选课") && !strings.Contains(link.Text, "进入") {
+				continue
+			}
+			s := CourseSession{Term: "当前学期", Name: link.Text}
+			s.EntryURL, s.ViewURL = classifySessionLinks([]htmlLink{link})
+			s.URL = sessionEntryURL(s.EntryURL, s.ViewURL)
+			add(s)
+		}
+	}
+
+	if len(sessions) == 0 {
+		return nil, errNoSessions
+	}
+	return sessions, nil
+}
+
+// sessionsFromTable reads the sessions of one table, skipping tables
+// without any session link
+func sessionsFromTable(table htmlTable) []CourseSession {
+	columns := map[string]int{"term": 0, "name": 1, "time": 2, "status": -1}
+	first := 0
+	for i, row := range table.Rows {
+		if !isSessionHeader(row) {
+			continue
+		}
+		for j, cell := range row.Cells {
+			switch {
+			case strings.Contains(cell.Text, "学年学期") || strings.Contains(cell.Text, "学期"):
+				columns["term"] = j
+			case strings.Contains(cell.Text, "选课名称") || strings.Contains(cell.Text, "名称"):
+				columns["name"] = j
+			case strings.Contains(cell.Text, "时间"):
+				columns["time"] = j
+			case strings.Contains(cell.Text, "状态"):
+				columns["status"] = j
+			}
+		}
+		first = i + 1
+		break
+	}
+
+	var sessions []CourseSession
+	for _, row := range table.Rows[first:] {
+		var links []htmlLink
+		for _, cell := range row.Cells {
+			links = append(links, cell.Links...)
+		}
+		entry, view := classifySessionLinks(links)
+		if entry == "" && view == "" {
+			continue
+		}
+
+		text := func(column string) string {
+			if i := columns[column]; i >= 0 && i < len(row.Cells) {
+				return row.Cells[i].Text
+			}
+			return ""
+		}
+
+		s := CourseSession{
+			Term:     text("term"),
+			Name:     text("name"),
+			Time:     text("time"),
+			Status:   normalizeSessionStatus(text("status")),
+			EntryURL: entry,
+			ViewURL:  view,
+			URL:      sessionEntryURL(entry, view),
+		}
+
+		// The time column moves around between schools, look for a date anywhere
+		if !sessionTimePattern.MatchString(s.Time) {
+			for _, cell := range row.Cells {
+				if sessionTimePattern.MatchString(cell.Text) {
+					s.Time = cell.Text
+					break
+				}
+			}
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+// isSessionHeader reports whether a row holds the column titles
+func isSessionHeader(row htmlRow) bool {
+	for _, cell := range row.Cells {
+		if strings.Contains(cell.Text, "选课名称") || strings.Contains(cell.Text, "学年学期") {
+			return len(cell.Links) == 0
+		}
+	}
+	return false
+}
+
+// classifySessionLinks picks the 进入选课 and 查看 links of a session row
+func classifySessionLinks(links []htmlLink) (entry string, view string) {
+	for _, link := range links {
+		target := linkTarget(link)
+		if target == "" {
+			continue
+		}
+		switch {
+		case strings.Contains(target, "xklc_view") || strings.Contains(link.Text, "查看"):
+			if view == "" {
+				view = target
+			}
+		case strings.Contains(target, "xsxk_index") || strings.Contains(link.Text, "进入") || strings.Contains(link.Text, "选课"):
+			if entry == "" {
+				entry = target
+			}
+		}
+	}
+	return entry, view
+}
+
+// linkTarget returns the URL a link opens, looking inside javascript: hrefs
+// and onclick handlers when the href is not a plain URL
+func linkTarget(link htmlLink) string {
+	href := strings.TrimSpace(link.Href)
+	if href != "" && href != "#" && !strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return href
+	}
+	for _, code := range []string{link.Onclick, link.Href} {
+		if m := quotedPathPattern.FindStringSubmatch(code); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// sessionEntryURL returns the URL that enters a session. This school serves
+// the selection page at yxxsxk_index, so the xsxk_index entry link and the
+// xklc_view link, whichever exists, are pointed there keeping their query.
+func sessionEntryURL(entry string, view string) string {
+	link := entry
+	if link == "" {
+		link = view
+	}
+	if link == "" {
+		return ""
+	}
+
+	base, query, hasQuery := strings.Cut(link, "?")
+	if last := path.Base(base); last == "xklc_view" || last == "xsxk_index" {
+		base = strings.TrimSuffix(base, last) + "yxxsxk_index"
+	}
+	if hasQuery {
+		return base + "?" + query
+	}
+	return base
+}
+
+// normalizeSessionStatus maps a status cell to 未开始, 进行中 or 已结束
+func normalizeSessionStatus(text string) string {
+	switch {
+	case strings.Contains(text, "未开始"):
+		return "未开始"
+	case strings.Contains(text, "进行") || strings.Contains(text, "开放"):
+		return "进行中"
+	case strings.Contains(text, "结束") || strings.Contains(text, "关闭"):
+		return "已结束"
+	}
+	return ""
+}
+
+// sessionStatus derives the status of a session from a time range such as
+// "2025-06-20 12:00~2025-06-25 12:00". A range without times of day covers
+// the whole end date.
+func sessionStatus(timeRange string, now time.Time) string {
+	matches := sessionTimePattern.FindAllString(timeRange, 2)
+	if len(matches) < 2 {
+		return ""
+	}
+
+	start, okStart := parseSessionTime(matches[0])
+	end, okEnd := parseSessionTime(matches[1])
+	if !okStart || !okEnd {
+		return ""
+	}
+	if !strings.Contains(matches[1], ":") {
+		end = end.AddDate(0, 0, 1)
+	}
+
+	switch {
+	case now.Before(start):
+		return "未开始"
+	case now.Before(end):
+		return "进行中"
+	}
+	return "已结束"
+}
+
+// parseSessionTime parses a date with an optional time of day in China time
+func parseSessionTime(s string) (time.Time, bool) {
+	s = strings.Join(strings.Fields(strings.Replace(s, "T", " ", 1)), " ")
+	for _, layout := range []string{"2006-1-2 15:04:05", "2006-1-2 15:04", "2006-1-2"} {
+		if t, err := time.ParseInLocation(layout, s, chinaTime); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
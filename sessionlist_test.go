@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestParseSessionList parses every testdata/xklc_list_*.html fixture and
+// compares the sessions with the matching .golden file
+func TestParseSessionList(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "xklc_list_*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures found")
+	}
+
+	// A fixed clock inside the 2025-09-01 ~ 2025-09-05 sessions
+	now := time.Date(2025, 9, 2, 10, 0, 0, 0, chinaTime)
+
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".html")
+		t.Run(name, func(t *testing.T) {
+			page, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sessions, err := parseSessionList(string(page), now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareGolden(t, fixture, sessions)
+		})
+	}
+}
+
+// compareGolden encodes v as indented JSON and compares it with the .golden
+// file next to fixture, rewriting the file first with -update
+func compareGolden(t *testing.T, fixture string, v any) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.Bytes()
+
+	golden := strings.TrimSuffix(fixture, ".html") + ".golden"
+	if *updateGolden {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("result differs from %s\ngot:\n%s\nwant:\n%s", golden, got, want)
+	}
+}
+
+// TestParseSessionListEmpty checks that a page without sessions is an error
+func TestParseSessionListEmpty(t *testing.T) {
+	if _, err := parseSessionList("<html><body><table><tr><td>暂无数据</td></tr></table></body></html>", time.Now()); err == nil {
+		t.Error("expected an error for a page without sessions")
+	}
+}
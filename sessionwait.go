@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// waitForSession polls the session list until an open session whose name or
+// term contains filter appears, and returns it. An empty filter matches any
+// open session. Sessions that are published but not started yet keep the
+// polling going until they open.
+func waitForSession(ctx context.Context, filter string) (CourseSession, error) {
+	interval, err := sessionPoll()
+	if err != nil {
+		return CourseSession{}, err
+	}
+
+	logf("正在等待匹配 %q 的选课会话发布，每 %v 查询一次，按 Ctrl+C 停止\n", filter, interval)
+	for polls := 1; ; polls++ {
+		wait := interval
+
+		sessions, err := getSessionList(primaryEgress.getCookies())
+		var throttled *throttledError
+		switch {
+		case err == nil:
+			sessionList = sessions
+			session, found, pending := matchSession(sessions, filter)
+			if found {
+				logf("选课会话已开放: %s - %s\n", session.Term, session.Name)
+				return session, nil
+			}
+			if pending != nil {
+				logf("第 %d 次查询: 选课会话 %s 已发布但尚未开始 (%s)\n", polls, pending.Name, pending.Time)
+			} else {
+				logf("第 %d 次查询: 还没有匹配的选课会话\n", polls)
+			}
+		case errors.Is(err, errNoSessions):
+			logf("第 %d 次查询: 选课会话列表为空\n", polls)
+		case errors.As(err, &throttled):
+			logf("第 %d 次查询: 正在被限流 (%s)\n", polls, throttled.kind)
+			wait = max(wait, throttled.delay)
+		case errors.Is(err, errSessionExpired):
+			logf("第 %d 次查询: 登录已过期，重新登录...\n", polls)
+			if err := reloginPrimary(ctx); err != nil {
+				return CourseSession{}, err
+			}
+		default:
+			logf("第 %d 次查询失败: %v\n", polls, err)
+		}
+
+		// Spread the polls a little so that many clients do not hit the server together
+		wait += time.Duration((rand.Float64()*0.4 - 0.2) * float64(wait))
+		if !sleepContext(ctx, wait) {
+			return CourseSession{}, ctx.Err()
+		}
+	}
+}
+
+// matchSession returns the first open session matching filter. If a matching
+// session exists but has not started yet, it is returned as pending.
+func matchSession(sessions []CourseSession, filter string) (CourseSession, bool, *CourseSession) {
+	var pending *CourseSession
+	for i, s := range sessions {
+		if filter != "" && !strings.Contains(s.Name, filter) && !strings.Contains(s.Term, filter) {
+			continue
+		}
+		switch s.Status {
+		case "已结束":
+			continue
+		case "未开始":
+			if pending == nil {
+				pending = &sessions[i]
+			}
+			continue
+		}
+		return s, true, nil
+	}
+	return CourseSession{}, false, pending
+}
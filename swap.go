@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// Timing of a swap: how often the target is checked for seats, how long it is
+// tried once the old course is dropped, and how long re-selecting the old
+// course is tried if the target fails
+const (
+	swapPoll           = 10 * time.Second
+	defaultSwapWindow  = 10 * time.Second
+	swapRollbackWindow = 30 * time.Second
+)
+
+// runSwap moves from a held course to another one. It waits until the target
+// has seats, drops the held course, tries the target in a burst for a short
+// window and, if that fails, re-selects the dropped course right away.
+func runSwap(args []string) {
+	if len(args) < 2 || len(args) > 3 {
+		fmt.Println("用法: swap <已选课程号|选课ID> <目标课程号|选课ID> [尝试时长]")
+		return
+	}
+	window := defaultSwapWindow
+	if len(args) == 3 {
+		d, err := time.ParseDuration(args[2])
+		if err != nil || d <= 0 {
+			fmt.Println("尝试时长格式错误，应为 10s、1m 等")
+			return
+		}
+		window = d
+	}
+
+	held, err := fetchSelectedCourses(primaryEgress)
+	if err != nil {
+		fmt.Printf("获取已选课程失败: %v\n", err)
+		return
+	}
+	selectedCache = held
+
+	var from *selectedCourse
+	for i, c := range held {
+		if c.Jx0404id == args[0] || c.Kch == args[0] {
+			from = &held[i]
+			break
+		}
+	}
+	if from == nil {
+		fmt.Printf("已选课程中没有 %s，输入 selected 查看\n", args[0])
+		return
+	}
+
+	matches := findCourses(args[1])
+	switch {
+	case len(matches) == 0:
+		fmt.Printf("课程号 %s 不存在\n", args[1])
+		return
+	case len(matches) > 1:
+		fmt.Printf("课程 %s 有 %d 个教学班，请用选课ID指定:\n", args[1], len(matches))
+		for _, c := range matches {
+			fmt.Printf("  %s  %s %s 剩余 %s\n", c.Jx0404id, c.Skls, c.Sksj, c.Syrs)
+		}
+		return
+	}
+	to := matches[0]
+	if to.Jx0404id == from.Jx0404id {
+		fmt.Println("目标教学班就是已选的教学班")
+		return
+	}
+	for _, c := range held {
+		if c.Jx0404id == to.Jx0404id {
+			fmt.Printf("已经选了 %s %s\n", to.Kch, to.Kcmc)
+			return
+		}
+	}
+
+	// The section to fall back to, with the catalog details if it is listed
+	back := Course{Kch: from.Kch, Kcmc: from.Kcmc, Jx0404id: from.Jx0404id, Skls: from.Skls, Sksj: from.Sksj}
+	if found := findCourses(from.Jx0404id); len(found) == 1 {
+		back = found[0]
+	}
+
+	answer := readInput(fmt.Sprintf("将退选 %s %s 并改选 %s %s (%s %s)，确定吗? (y/N): ",
+		from.Kch, from.Kcmc, to.Kch, to.Kcmc, to.Skls, to.Sksj))
+	if !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+		fmt.Println("已取消")
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	logins.startRun(stop)
+	defer logins.endRun()
+	selectedCache = nil
+
+	// Step 1: wait for a seat in the target, holding on to the old course
+	swapStep("wait", "等待 %s %s 出现空位，每 %v 检查一次，按 Ctrl+C 放弃换课", to.Kch, to.Kcmc, swapPoll)
+	if !waitForSeats(ctx, to) {
+		swapStep("cancelled", "已放弃换课，仍保留 %s %s", from.Kch, from.Kcmc)
+		return
+	}
+
+	// Step 2: drop the old course. From here on the old course must be
+	// re-selected unless the target is confirmed, even after Ctrl+C.
+	if err := dropCourse(primaryEgress, from.Jx0404id); err != nil {
+		swapStep("drop_failed", "退选 %s %s 失败，换课中止: %v", from.Kch, from.Kcmc, err)
+		return
+	}
+	swapStep("dropped", "已退选 %s %s", from.Kch, from.Kcmc)
+
+	// Step 3: try the target in a burst for the window
+	swapStep("select", "开始抢选 %s %s，最长 %v", to.Kch, to.Kcmc, window)
+	if grabSection(ctx, to, window) {
+		swapStep("done", "换课成功: %s %s → %s %s", from.Kch, from.Kcmc, to.Kch, to.Kcmc)
+		return
+	}
+
+	// Step 4: roll back to the old course, unless the user stopped at the
+	// credential prompt and no request can get through any more
+	if logins.stopped() {
+		swapStep("stopped", "⚠️  已停止换课: 账号或密码被拒绝，%s %s (选课ID %s) 已退选，请重新登录后手动选回",
+			back.Kch, back.Kcmc, back.Jx0404id)
+		return
+	}
+	swapStep("rollback", "未能选上 %s %s，立即重新选回 %s %s", to.Kch, to.Kcmc, back.Kch, back.Kcmc)
+	if grabSection(context.Background(), back, swapRollbackWindow) {
+		swapStep("rolled_back", "已重新选回 %s %s", back.Kch, back.Kcmc)
+		return
+	}
+	swapStep("rollback_failed", "⚠️  未能重新选回 %s %s (选课ID %s)，请立即手动处理", back.Kch, back.Kcmc, back.Jx0404id)
+}
+
+// swapStep logs one step of a swap and emits it as an event
+func swapStep(step string, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Printf("%s [换课] %s\n", time.Now().Format("15:04:05"), msg)
+	emitEvent("swap", map[string]any{"step": step, "message": msg})
+}
+
+// waitForSeats reloads the catalog until a section has seats and reports
+// false if ctx was cancelled first
+func waitForSeats(ctx context.Context, c Course) bool {
+	for {
+		wait := swapPoll
+
+		courses, err := loadCourseList(primaryEgress.getCookies())
+		var throttled *throttledError
+		switch {
+		case err == nil:
+			found := false
+			for _, course := range courses {
+				if course.Jx0404id != c.Jx0404id {
+					continue
+				}
+				found = true
+				// An unknown count may hide free seats, so it is tried too
+				if mayHaveSeats(course) {
+					swapStep("seats", "%s %s 剩余 %s", c.Kch, c.Kcmc, course.Syrs)
+					return true
+				}
+			}
+			if !found {
+				fmt.Printf("%s 课程列表中暂时没有教学班 %s\n", time.Now().Format("15:04:05"), c.Jx0404id)
+			}
+		case errors.As(err, &throttled):
+			fmt.Printf("正在被限流 (%s)\n", throttled.kind)
+			wait = max(wait, throttled.delay)
+		case errors.Is(err, errSessionExpired):
+			fmt.Println("登录已过期，重新登录...")
+			if reloginPrimary(ctx) != nil {
+				return false
+			}
+		default:
+			fmt.Printf("获取课程列表失败: %v\n", err)
+		}
+
+		if !sleepContext(ctx, wait) {
+			return false
+		}
+	}
+}
+
+// grabSection tries a section for window, keeping burst requests in flight
+// the whole time, and reports whether it was selected and shows up in the
+// 已选课程 list. The attempt shows up in the status command.
+func grabSection(ctx context.Context, c Course, window time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, window)
+	defer cancel()
+
+	t := &courseTarget{kch: c.Kch, name: c.Kcmc, jx0404id: c.Jx0404id, priority: 1, state: "选课中", section: c}
+	lastRun = []*courseTarget{t}
+
+	ok := runCourseWorker(ctx, t, primaryEgress, serverNow().Add(window))
+	if ok && !t.snapshot().confirmed {
+		// The list could not be read after the success. A swap must not
+		// skip its rollback on an unconfirmed success, so read it again.
+		swapStep("verify", "%s %s 返回成功但未能确认，重新读取已选课程", c.Kch, c.Kcmc)
+		ok = sectionSelected(t.currentSection().Jx0404id)
+		t.setConfirmed(ok)
+	}
+	if ok {
+		t.setState("成功")
+	} else {
+		t.setState("失败")
+	}
+	return ok
+}
+
+// sectionSelected reads the 已选课程 list a few times and reports whether it
+// shows a section. A list that cannot be read counts as not showing it.
+func sectionSelected(id string) bool {
+	for read := 1; read <= confirmReads; read++ {
+		courses, err := fetchSelectedCourses(primaryEgress)
+		if err == nil {
+			for _, c := range courses {
+				if c.Jx0404id == id {
+					return true
+				}
+			}
+		} else {
+			fmt.Printf("读取已选课程失败: %v\n", err)
+		}
+		if read < confirmReads {
+			time.Sleep(confirmBackoff)
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ANSI colours used in tables
+const (
+	colorRed   = "31"
+	colorGreen = "32"
+)
+
+// colorEnabled reports whether tables may use colour, see setupColor
+var colorEnabled bool
+
+// setupColor enables colour on ANSI terminals unless disabled by flag or the NO_COLOR convention
+func setupColor(disabled bool) {
+	_, noColor := os.LookupEnv("NO_COLOR")
+	colorEnabled = !disabled && !noColor && ansiEnabled && isTerminal(os.Stdout.Fd())
+}
+
+// colorize wraps text in an ANSI colour if colour is enabled
+func colorize(text string, color string) string {
+	if !colorEnabled || color == "" {
+		return text
+	}
+	return "\x1b[" + color + "m" + text + "\x1b[0m"
+}
+
+// tableColumn describes how one column of a table is laid out
+type tableColumn struct {
+	title string
+	min   int                      // Never shrink below this many columns
+	max   int                      // Never grow beyond this many columns, 0 for no limit
+	wrap  bool                     // Wrap long cells over several lines instead of cutting them
+	color func(cell string) string // Colour of a cell, "" for none
+}
+
+// table renders rows in columns sized by terminal display width, so that
+// Chinese text lines up, and fits them to the terminal width
+type table struct {
+	columns []tableColumn
+	rows    [][]string
+}
+
+// newTable creates a table with the given columns
+func newTable(columns ...tableColumn) *table {
+	return &table{columns: columns}
+}
+
+// addRow appends a row, one cell per column
+func (t *table) addRow(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// cell returns the text of a cell with line breaks flattened
+func (t *table) cell(row []string, col int) string {
+	if col >= len(row) {
+		return ""
+	}
+	return strings.Join(strings.Fields(row[col]), " ")
+}
+
+// layout returns the width of every column. Columns start at the width of
+// their content, capped at their maximum. If the table is wider than the
+// terminal, the widest columns give up space first, down to their minimum.
+func (t *table) layout(termWidth int) []int {
+	widths := make([]int, len(t.columns))
+	for i, column := range t.columns {
+		widths[i] = displayWidth(column.title)
+		for _, row := range t.rows {
+			widths[i] = max(widths[i], displayWidth(t.cell(row, i)))
+		}
+		if column.max > 0 {
+			widths[i] = min(widths[i], column.max)
+		}
+	}
+
+	if termWidth <= 0 {
+		return widths
+	}
+
+	total := 2 * (len(widths) - 1)
+	for _, w := range widths {
+		total += w
+	}
+	for total > termWidth-1 {
+		widest := -1
+		for i, w := range widths {
+			if w > max(t.columns[i].min, 1) && (widest < 0 || w > widths[widest]) {
+				widest = i
+			}
+		}
+		if widest < 0 {
+			break
+		}
+		widths[widest]--
+		total--
+	}
+	return widths
+}
+
+// render prints the table to stdout
+func (t *table) render() {
+	termWidth := 0
+	if isTerminal(os.Stdout.Fd()) {
+		termWidth = terminalWidth(os.Stdout.Fd())
+	}
+	widths := t.layout(termWidth)
+
+	var header []string
+	total := 2 * (len(widths) - 1)
+	for i, column := range t.columns {
+		header = append(header, padWidth(column.title, widths[i]))
+		total += widths[i]
+	}
+	fmt.Println(strings.TrimRight(strings.Join(header, "  "), " "))
+	fmt.Println(strings.Repeat("-", total))
+
+	for _, row := range t.rows {
+		// Split every cell into the lines it occupies
+		lines := make([][]string, len(t.columns))
+		height := 1
+		for i, column := range t.columns {
+			text := t.cell(row, i)
+			if column.wrap {
+				lines[i] = wrapWidth(text, widths[i])
+			} else {
+				lines[i] = []string{truncateWidth(text, widths[i])}
+			}
+			height = max(height, len(lines[i]))
+		}
+
+		for l := 0; l < height; l++ {
+			var cells []string
+			for i, column := range t.columns {
+				text := ""
+				if l < len(lines[i]) {
+					text = lines[i][l]
+				}
+				cell := padWidth(text, widths[i])
+				if column.color != nil {
+					cell = colorize(cell, column.color(t.cell(row, i)))
+				}
+				cells = append(cells, cell)
+			}
+			fmt.Println(strings.TrimRight(strings.Join(cells, "  "), " "))
+		}
+	}
+}
+
+// seatsColor shows full courses in red and courses with seats left in green
+func seatsColor(cell string) string {
+	switch {
+	case cell == "满":
+		return colorRed
+	case cell != "" && cell != "0" && cell != "-":
+		return colorGreen
+	}
+	return ""
+}
+
+// sessionStatusColor shows open sessions in green and closed ones in red
+func sessionStatusColor(cell string) string {
+	switch cell {
+	case "进行中":
+		return colorGreen
+	case "已结束":
+		return colorRed
+	}
+	return ""
+}
@@ -0,0 +1,25 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import "errors"
+
+// isTerminal always reports false on platforms without terminal support
+func isTerminal(fd uintptr) bool {
+	return false
+}
+
+// makeRaw is not supported on this platform
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+// terminalWidth is unknown on this platform
+func terminalWidth(fd uintptr) int {
+	return 0
+}
+
+// enableANSI reports that ANSI escapes are not available on this platform
+func enableANSI(fd uintptr) bool {
+	return false
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// Console mode flags from the Windows console API
+const (
+	enableProcessedInput            = 0x0001
+	enableLineInput                 = 0x0002
+	enableEchoInput                 = 0x0004
+	enableVirtualTerminalInput      = 0x0200
+	enableVirtualTerminalProcessing = 0x0004
+)
+
+var (
+	kernel32                       = syscall.NewLazyDLL("kernel32.dll")
+	procGetConsoleMode             = kernel32.NewProc("GetConsoleMode")
+	procSetConsoleMode             = kernel32.NewProc("SetConsoleMode")
+	procGetConsoleScreenBufferInfo = kernel32.NewProc("GetConsoleScreenBufferInfo")
+)
+
+// getConsoleMode reads the console mode of a handle
+func getConsoleMode(fd uintptr) (uint32, error) {
+	var mode uint32
+	r, _, err := procGetConsoleMode.Call(fd, uintptr(unsafe.Pointer(&mode)))
+	if r == 0 {
+		return 0, err
+	}
+	return mode, nil
+}
+
+// setConsoleMode changes the console mode of a handle
+func setConsoleMode(fd uintptr, mode uint32) error {
+	r, _, err := procSetConsoleMode.Call(fd, uintptr(mode))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+// isTerminal reports whether fd is a console
+func isTerminal(fd uintptr) bool {
+	_, err := getConsoleMode(fd)
+	return err == nil
+}
+
+// makeRaw switches the console input to raw mode with VT escape sequences for
+// special keys, and returns a function restoring the previous mode
+func makeRaw(fd uintptr) (func(), error) {
+	old, err := getConsoleMode(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := old &^ (enableEchoInput | enableProcessedInput | enableLineInput)
+	raw |= enableVirtualTerminalInput
+	if err := setConsoleMode(fd, raw); err != nil {
+		return nil, err
+	}
+	return func() { setConsoleMode(fd, old) }, nil
+}
+
+// terminalWidth returns the number of columns of the console window, or 0 if unknown
+func terminalWidth(fd uintptr) int {
+	var info struct {
+		Size              struct{ X, Y int16 }
+		CursorPosition    struct{ X, Y int16 }
+		Attributes        uint16
+		Window            struct{ Left, Top, Right, Bottom int16 }
+		MaximumWindowSize struct{ X, Y int16 }
+	}
+	r, _, _ := procGetConsoleScreenBufferInfo.Call(fd, uintptr(unsafe.Pointer(&info)))
+	if r == 0 {
+		return 0
+	}
+	return int(info.Window.Right-info.Window.Left) + 1
+}
+
+// enableANSI turns on VT escape sequence processing for console output and
+// reports whether ANSI escapes can be used
+func enableANSI(fd uintptr) bool {
+	mode, err := getConsoleMode(fd)
+	if err != nil {
+		return false
+	}
+	return setConsoleMode(fd, mode|enableVirtualTerminalProcessing) == nil
+}
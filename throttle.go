@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// responseKind classifies a server response before it is parsed
+type responseKind int
+
+const (
+	respOK             responseKind = iota // Expected content (JSON or page)
+	respSessionExpired                     // Login page or session timeout message
+	respWAFBlocked                         // WAF interstitial or block page
+	respRateLimited                        // HTTP 429
+	respForbidden                          // HTTP 403
+	respServerError                        // HTTP 5xx
+	respUnexpected                         // HTML that is neither the login page nor a WAF page
+)
+
+// String returns a human readable description of the response kind
+func (k responseKind) String() string {
+	switch k {
+	case respOK:
+		return "正常"
+	case respSessionExpired:
+		return "会话过期"
+	case respWAFBlocked:
+		return "WAF拦截"
+	case respRateLimited:
+		return "请求过于频繁(429)"
+	case respForbidden:
+		return "拒绝访问(403)"
+	case respServerError:
+		return "服务器错误(5xx)"
+	case respUnexpected:
+		return "未知页面"
+	}
+	return "未知"
+}
+
+// isThrottled reports whether the response means we should slow down rather
+// than log in again. An unexpected page is neither; callers decide whether it
+// is an error.
+func (k responseKind) isThrottled() bool {
+	switch k {
+	case respWAFBlocked, respRateLimited, respForbidden, respServerError:
+		return true
+	}
+	return false
+}
+
+// Markers of session timeout messages. The login page itself is recognised
+// by its form, since its field names and title also turn up in other pages.
+var sessionExpiredMarkers = []string{
+	"请重新登录", "已在别处登录", "登录超时",
+}
+
+// isLoginForm reports whether a page holds the QZ login form
+func isLoginForm(page string) bool {
+	return strings.Contains(page, "<form") &&
+		strings.Contains(page, "userAccount") && strings.Contains(page, "userPassword")
+}
+
+// Markers found on Huawei Cloud WAF and generic WAF block pages. They are only
+// looked for in pages, since course names and descriptions in JSON answers
+// may well mention a firewall.
+var wafMarkers = []string{
+	"HWWAF", "Web应用防火墙", "web应用防火墙", "访问被拦截", "请求被拦截",
+	"安全拦截", "拦截了您的访问", "对网站造成安全威胁", "访问过于频繁", "请求过于频繁",
+}
+
+// classifyResponse decides what kind of response the server sent. Status codes
+// are checked first, then bodies are told apart by their markers.
+func classifyResponse(status int, body []byte) responseKind {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return respRateLimited
+	case status == http.StatusForbidden:
+		return respForbidden
+	case status >= 500:
+		return respServerError
+	}
+
+	bodyStr := string(body)
+	trimmed := strings.TrimSpace(bodyStr)
+	isJSON := strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")
+	if !isJSON {
+		for _, marker := range wafMarkers {
+			if strings.Contains(bodyStr, marker) {
+				return respWAFBlocked
+			}
+		}
+		if isLoginForm(bodyStr) {
+			return respSessionExpired
+		}
+	}
+	for _, marker := range sessionExpiredMarkers {
+		if strings.Contains(bodyStr, marker) {
+			return respSessionExpired
+		}
+	}
+
+	if isJSON {
+		return respOK
+	}
+	if strings.Contains(strings.ToLower(bodyStr), "<html") {
+		return respUnexpected
+	}
+	return respOK
+}
+
+// throttledError is returned when a request was rejected by rate limiting or the WAF
+type throttledError struct {
+	kind  responseKind
+	delay time.Duration
+}
+
+func (e *throttledError) Error() string {
+	return fmt.Sprintf("被服务器限流或拦截(%s)，%v 后重试", e.kind, e.delay.Round(time.Millisecond))
+}
+
+// Backoff parameters for throttled responses
+const (
+	backoffBase = 2 * time.Second
+	backoffMax  = 2 * time.Minute
+)
+
+// hostBackoff is the backoff state of one host as seen from one egress
+type hostBackoff struct {
+	failures int
+	until    time.Time
+}
+
+// backoffRegistry tracks exponential backoff per host
+type backoffRegistry struct {
+	mu    sync.Mutex
+	hosts map[string]*hostBackoff
+}
+
+// Global backoff registry shared by all workers
+var backoffs = &backoffRegistry{hosts: make(map[string]*hostBackoff)}
+
+// backoffKey identifies a host as reached through an egress, since the WAF
+// throttles per client address
+func backoffKey(e *egress, host string) string {
+	return e.name + "|" + host
+}
+
+// wait blocks until the backoff period of a host has passed and reports
+// false if ctx was cancelled first
+func (r *backoffRegistry) wait(ctx context.Context, key string) bool {
+	r.mu.Lock()
+	state, ok := r.hosts[key]
+	var until time.Time
+	if ok {
+		until = state.until
+	}
+	r.mu.Unlock()
+
+	return sleepContext(ctx, time.Until(until))
+}
+
+// failure records a throttled response and returns how long the host is paused.
+// The delay doubles with each consecutive failure and carries random jitter so
+// that workers do not retry in lockstep. A Retry-After header takes precedence
+// when it asks for a longer pause.
+func (r *backoffRegistry) failure(key string, retryAfter string) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, ok := r.hosts[key]
+	if !ok {
+		state = &hostBackoff{}
+		r.hosts[key] = state
+	}
+	state.failures++
+
+	delay := backoffBase << (state.failures - 1)
+	if delay > backoffMax || delay <= 0 {
+		delay = backoffMax
+	}
+	// Equal jitter: keep half of the delay, randomize the other half
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+	if seconds, err := strconv.Atoi(strings.TrimSpace(retryAfter)); err == nil {
+		if d := time.Duration(seconds) * time.Second; d > delay {
+			delay = d
+		}
+	}
+
+	// Never shorten a pause another worker already started
+	until := time.Now().Add(delay)
+	if until.After(state.until) {
+		state.until = until
+	}
+	return time.Until(state.until)
+}
+
+// success clears the backoff state of a host
+func (r *backoffRegistry) success(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.hosts, key)
+}
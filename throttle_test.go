@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+// TestClassifyResponse covers the status codes and the bodies the server and
+// its WAF send
+func TestClassifyResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   responseKind
+	}{
+		{"json", 200, `{"success":true,"message":"选课成功"}`, respOK},
+		{"json list", 200, ` [{"kch":"A1"}]`, respOK},
+		{"plain text", 200, `ok`, respOK},
+		{"429", 429, ``, respRateLimited},
+		{"403", 403, `<html>forbidden</html>`, respForbidden},
+		{"502", 502, `<html>bad gateway</html>`, respServerError},
+		{"huawei waf", 200, `<html><title>HWWAF</title><body>您的访问被拦截</body></html>`, respWAFBlocked},
+		{"waf block page", 200, `<html><body>Web应用防火墙 拦截了您的访问</body></html>`, respWAFBlocked},
+		{"login page", 200, `<html><form action="LoginToXk"><input name="userAccount"><input name="userPassword"></form></html>`, respSessionExpired},
+		{"page with a login link", 200, `<html><body><a href="LoginToXk">用户登录</a> userAccount</body></html>`, respUnexpected},
+		{"login words in json", 200, `{"aaData":[{"kcmc":"用户登录与userAccount设计"}]}`, respOK},
+		{"timeout message", 200, `<script>alert('登录超时，请重新登录');</script>`, respSessionExpired},
+		{"json session expired", 200, `{"success":false,"message":"请重新登录"}`, respSessionExpired},
+		{"other page", 200, `<html><body>系统维护中</body></html>`, respUnexpected},
+		{"waf in a course name", 200, `{"aaData":[{"kcmc":"Web应用防火墙与WAF技术"}]}`, respOK},
+		{"waf in a page", 200, `<html><body>课程: WAF 配置实践</body></html>`, respUnexpected},
+	}
+	for _, tt := range tests {
+		if got := classifyResponse(tt.status, []byte(tt.body)); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestIsThrottled checks that only WAF pages and HTTP errors slow us down
+func TestIsThrottled(t *testing.T) {
+	throttled := map[responseKind]bool{
+		respOK:             false,
+		respSessionExpired: false,
+		respWAFBlocked:     true,
+		respRateLimited:    true,
+		respForbidden:      true,
+		respServerError:    true,
+		respUnexpected:     false,
+	}
+	for kind, want := range throttled {
+		if got := kind.isThrottled(); got != want {
+			t.Errorf("%s: throttled %v, want %v", kind, got, want)
+		}
+	}
+}
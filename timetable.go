@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// timeSlot is one teaching period of one weekday in one teaching week
+type timeSlot struct {
+	week    int
+	weekday int
+	period  int
+}
+
+// courseSlots returns every time slot a course occupies. The week/day/period
+// list sent with the course is used when present, otherwise the slots are
+// expanded from the arrangements.
+func courseSlots(c Course) map[timeSlot]struct{} {
+	slots := make(map[timeSlot]struct{})
+
+	for _, z := range c.ZcxqjcList {
+		week, err1 := strconv.Atoi(z.Zc)
+		weekday, err2 := strconv.Atoi(z.Xq)
+		period, err3 := strconv.Atoi(z.Jc)
+		if err1 == nil && err2 == nil && err3 == nil {
+			slots[timeSlot{week, weekday, period}] = struct{}{}
+		}
+	}
+	if len(slots) > 0 {
+		return slots
+	}
+
+	for _, kkap := range c.KkapList {
+		weekday, err := strconv.Atoi(kkap.Xq)
+		if err != nil {
+			continue
+		}
+		for _, week := range arrangementWeeks(kkap) {
+			for _, period := range parsePeriods(kkap.Skjcmc) {
+				slots[timeSlot{week, weekday, period}] = struct{}{}
+			}
+		}
+	}
+	return slots
+}
+
+// parsePeriods expands skjcmc strings such as "9-10" or "01-02节"; they use
+// the same range syntax as teaching weeks
+func parsePeriods(skjcmc string) []int {
+	return parseWeekRanges(strings.TrimSuffix(strings.TrimSpace(skjcmc), "节"))
+}
+
+// slotsOverlap reports whether two slot sets share any slot
+func slotsOverlap(a, b map[timeSlot]struct{}) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	for slot := range a {
+		if _, ok := b[slot]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// coursesConflict reports whether two courses meet at the same time
+func coursesConflict(a, b Course) bool {
+	return slotsOverlap(courseSlots(a), courseSlots(b))
+}
@@ -0,0 +1,113 @@
+package main
+
+import "strings"
+
+// runeWidth returns the number of terminal columns a rune occupies: 0 for
+// combining marks and control characters, 2 for East Asian wide and fullwidth
+// characters, 1 otherwise
+func runeWidth(r rune) int {
+	switch {
+	case r == 0:
+		return 0
+	case r < 32 || (r >= 0x7f && r < 0xa0):
+		return 0
+	case r >= 0x0300 && r <= 0x036f, r >= 0x200b && r <= 0x200f, r >= 0xfe00 && r <= 0xfe0f:
+		return 0
+	case r >= 0x1100 && r <= 0x115f, // Hangul Jamo
+		r >= 0x2e80 && r <= 0x303e,   // CJK radicals, punctuation
+		r >= 0x3041 && r <= 0x33ff,   // Kana, CJK symbols
+		r >= 0x3400 && r <= 0x4dbf,   // CJK extension A
+		r >= 0x4e00 && r <= 0x9fff,   // CJK unified ideographs
+		r >= 0xa000 && r <= 0xa4cf,   // Yi
+		r >= 0xac00 && r <= 0xd7a3,   // Hangul syllables
+		r >= 0xf900 && r <= 0xfaff,   // CJK compatibility ideographs
+		r >= 0xfe30 && r <= 0xfe4f,   // CJK compatibility forms
+		r >= 0xff00 && r <= 0xff60,   // Fullwidth forms
+		r >= 0xffe0 && r <= 0xffe6,   // Fullwidth signs
+		r >= 0x1f300 && r <= 0x1f64f, // Emoji
+		r >= 0x1f900 && r <= 0x1f9ff, // Supplemental symbols
+		r >= 0x20000 && r <= 0x3fffd: // CJK extensions B and later
+		return 2
+	}
+	return 1
+}
+
+// displayWidth returns the number of terminal columns a string occupies
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// truncateWidth cuts a string to at most width columns, marking the cut with "…"
+func truncateWidth(s string, width int) string {
+	if displayWidth(s) <= width {
+		return s
+	}
+	if width <= 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	used := 0
+	for _, r := range s {
+		w := runeWidth(r)
+		if used+w > width-1 {
+			break
+		}
+		b.WriteRune(r)
+		used += w
+	}
+	b.WriteString("…")
+	return b.String()
+}
+
+// padWidth truncates or pads a string with spaces to exactly width columns
+func padWidth(s string, width int) string {
+	s = truncateWidth(s, width)
+	if pad := width - displayWidth(s); pad > 0 {
+		s += strings.Repeat(" ", pad)
+	}
+	return s
+}
+
+// wrapWidth splits a string into lines of at most width columns. Lines break
+// at spaces when possible and anywhere between CJK characters otherwise.
+func wrapWidth(s string, width int) []string {
+	if width <= 0 || displayWidth(s) <= width {
+		return []string{s}
+	}
+
+	var lines []string
+	var line []rune
+	used := 0
+	lastSpace := -1
+	for _, r := range s {
+		w := runeWidth(r)
+		if used+w > width {
+			if lastSpace > 0 && r != ' ' {
+				lines = append(lines, string(line[:lastSpace]))
+				line = append([]rune(nil), line[lastSpace+1:]...)
+			} else {
+				lines = append(lines, strings.TrimRight(string(line), " "))
+				line = nil
+			}
+			used = displayWidth(string(line))
+			lastSpace = -1
+			if r == ' ' && len(line) == 0 {
+				continue
+			}
+		}
+		if r == ' ' {
+			lastSpace = len(line)
+		}
+		line = append(line, r)
+		used += w
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}